@@ -0,0 +1,139 @@
+package patt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"patt/pattern"
+)
+
+// Aggregator accumulates the captures of matching lines instead of printing
+// them, and writes a summary once all the input has been processed.
+type Aggregator interface {
+	Add(captures Captures)
+	Report(w io.Writer) error
+}
+
+// CaptureNotFoundError is returned when an aggregation refers to a capture
+// that is not defined by the search patterns.
+type CaptureNotFoundError struct {
+	Name    string
+	Pattern string
+}
+
+func (e *CaptureNotFoundError) Error() string {
+	return fmt.Sprintf("capture '%s' not found in pattern '%s'", e.Name, e.Pattern)
+}
+
+type multiAggregator []Aggregator
+
+func (ma multiAggregator) Add(captures Captures) {
+	for _, a := range ma {
+		a.Add(captures)
+	}
+}
+
+// Report writes the report of every aggregator, even when some fail, and
+// returns their joined errors.
+func (ma multiAggregator) Report(w io.Writer) error {
+	var errs []error
+	for _, a := range ma {
+		errs = append(errs, a.Report(w))
+	}
+	return errors.Join(errs...)
+}
+
+// reportAggregation writes the report of the aggregator to w, and the
+// incomplete reports of the distinct values over their limit as warnings to
+// warnings. It returns the other errors.
+func reportAggregation(a Aggregator, w, warnings io.Writer) error {
+	err := a.Report(w)
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	var failed []error
+	for _, err := range errs {
+		var limitErr *DistinctLimitError
+		if errors.As(err, &limitErr) {
+			fmt.Fprintf(warnings, "patt: warning: %v\n", limitErr)
+			continue
+		}
+		failed = append(failed, err)
+	}
+	return errors.Join(failed...)
+}
+
+// syncAggregator makes an Aggregator safe for concurrent use, by the workers
+// processing files and by periodic reports.
+type syncAggregator struct {
+	mu sync.Mutex
+	Aggregator
+}
+
+func (sa *syncAggregator) Add(captures Captures) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	sa.Aggregator.Add(captures)
+}
+
+func (sa *syncAggregator) Report(w io.Writer) error {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	return sa.Aggregator.Report(w)
+}
+
+// startReports reports the aggregation to w every interval until ctx is done
+// or the returned function is called. The function waits for an in-flight
+// report to finish, so a final report can be written after it.
+func startReports(ctx context.Context, a Aggregator, w io.Writer, interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = a.Report(w)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// checkCapture verifies that every pattern, or one of the and patterns,
+// defines the named capture.
+func checkCapture(patterns, and []string, name string) error {
+	for _, p := range and {
+		m, err := pattern.ParseLineFilter([]byte(p))
+		if err != nil {
+			return err
+		}
+		if slices.Contains(m.Names(), name) {
+			return nil
+		}
+	}
+	for _, p := range patterns {
+		m, err := pattern.ParseLineFilter([]byte(p))
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(m.Names(), name) {
+			return &CaptureNotFoundError{Name: name, Pattern: p}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package patt
+
+import (
+	"fmt"
+	"slices"
+
+	"patt/pattern"
+)
+
+// And returns a matcher of the lines matched by all the matchers, tried in
+// order until one does not match.
+func And(matchers ...LinesMatcher) LinesMatcher {
+	return andMatcher(matchers)
+}
+
+// Or returns a matcher of the lines matched by any of the matchers, tried in
+// order until one matches.
+func Or(matchers ...LinesMatcher) LinesMatcher {
+	return orMatcher(matchers)
+}
+
+// Not returns a matcher of the lines not matched by m.
+func Not(m LinesMatcher) LinesMatcher {
+	return notMatcher{m}
+}
+
+type andMatcher []LinesMatcher
+
+func (a andMatcher) Match(line []byte) bool {
+	for _, m := range a {
+		if !m.Match(line) {
+			return false
+		}
+	}
+	return true
+}
+
+type orMatcher []LinesMatcher
+
+func (o orMatcher) Match(line []byte) bool {
+	for _, m := range o {
+		if m.Match(line) {
+			return true
+		}
+	}
+	return false
+}
+
+type notMatcher struct {
+	m LinesMatcher
+}
+
+func (n notMatcher) Match(line []byte) bool {
+	return !n.m.Match(line)
+}
+
+// BooleanReplacer replaces the lines matched by one of its patterns, by all
+// of its and patterns and by none of its not patterns. Its template may use
+// the captures of the matching pattern and of the and patterns.
+//
+// Like MultiReplacer, Replace and Captures require that Match(line) has
+// previously returned true for the same line.
+type BooleanReplacer struct {
+	replacer *MultiReplacer
+	and      []LineReplacer
+	not      []LineReplacer
+	// notPatterns are the patterns of not, for Explain.
+	notPatterns []string
+	cond        LinesMatcher
+
+	// literals and names are the nodes of the template, literals[i] being nil
+	// for the capture names[i]. Without template, lines are unchanged.
+	literals [][]byte
+	names    []string
+}
+
+// NewBooleanReplacer creates a BooleanReplacer. The template may be empty to
+// leave matching lines unchanged, otherwise each of its captures must be
+// captured by every pattern or by one of the and patterns.
+func NewBooleanReplacer(patterns, and, not []string, template string, opts ...MultiReplacerOption) (*BooleanReplacer, error) {
+	replacer, err := NewMultiFilter(patterns, opts...)
+	if err != nil {
+		return nil, err
+	}
+	r := &BooleanReplacer{replacer: replacer, notPatterns: not}
+	var conds []LinesMatcher
+	andNames := map[string]bool{}
+	for _, p := range and {
+		filter, err := pattern.ParseLineFilter([]byte(p))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create filter for pattern '%s': %w", p, err)
+		}
+		f := matchFilter{PatternMatcher: &PatternMatcher{filter: *filter, pattern: p}}
+		r.and = append(r.and, f)
+		conds = append(conds, f)
+		for _, name := range filter.Names() {
+			andNames[name] = true
+		}
+	}
+	var nots []LinesMatcher
+	for _, p := range not {
+		f, err := NewFilter(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create filter for pattern '%s': %w", p, err)
+		}
+		r.not = append(r.not, f)
+		nots = append(nots, f)
+	}
+	if len(nots) > 0 {
+		conds = append(conds, Not(Or(nots...)))
+	}
+	r.cond = And(conds...)
+
+	if template == "" {
+		return r, nil
+	}
+	r.literals, r.names, err = pattern.ParseNodes(template)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range patterns {
+		m, err := pattern.New(p)
+		if err != nil {
+			return nil, err
+		}
+		names := map[string]bool{}
+		for _, name := range m.Names() {
+			names[name] = true
+		}
+		for i, name := range r.names {
+			if r.literals[i] == nil && !names[name] && !andNames[name] {
+				return nil, fmt.Errorf("failed to create replacer for pattern '%s' with template '%s': %w", p, template, &ReplaceNameNotFoundError{Name: name})
+			}
+		}
+	}
+	return r, nil
+}
+
+func (r *BooleanReplacer) Match(line []byte) bool {
+	return r.replacer.Match(line) && r.cond.Match(line)
+}
+
+func (r *BooleanReplacer) Replace(line []byte) []byte {
+	if r.literals == nil {
+		return line
+	}
+	captures := r.Captures(line)
+	var result []byte
+	for i, l := range r.literals {
+		if l != nil {
+			result = append(result, l...)
+		} else {
+			value, _ := captures.Get(r.names[i])
+			result = append(result, value...)
+		}
+	}
+	return result
+}
+
+// Captures returns the captures of the matching pattern, followed by those
+// of the and patterns.
+func (r *BooleanReplacer) Captures(line []byte) Captures {
+	c := r.replacer.Captures(line)
+	if len(r.and) == 0 {
+		return c
+	}
+	captures := Captures{Names: slices.Clone(c.Names), Values: slices.Clone(c.Values)}
+	for _, and := range r.and {
+		c := and.Captures(line)
+		captures.Names = append(captures.Names, c.Names...)
+		captures.Values = append(captures.Values, c.Values...)
+	}
+	return captures
+}
+
+// MatchedPattern returns the index of the pattern that matched the last line
+// passed to Match, or -1 if none did.
+func (r *BooleanReplacer) MatchedPattern() int {
+	return r.replacer.MatchedPattern()
+}
+
+// Stats returns the number of lines matched by each pattern, before the
+// and and not patterns are tried.
+func (r *BooleanReplacer) Stats() MatchStats {
+	return r.replacer.Stats()
+}
+
+// Clone returns a BooleanReplacer with the same patterns and its own match state.
+func (r *BooleanReplacer) Clone() LineReplacer {
+	c := *r
+	c.replacer = r.replacer.Clone().(*MultiReplacer)
+	return &c
+}
+
+// Explain returns why the line does not match the patterns, or the and
+// patterns, or which not patterns it matches.
+func (r *BooleanReplacer) Explain(line []byte) []Explanation {
+	// Match would count the line in the stats of the patterns.
+	matched := slices.ContainsFunc(r.replacer.replacers, func(p LineReplacer) bool { return p.Match(line) })
+	if !matched || !And(r.replacer.lineFilters...).Match(line) {
+		return r.replacer.Explain(line)
+	}
+	var explanations []Explanation
+	for _, and := range r.and {
+		explanations = append(explanations, explain(and, line)...)
+	}
+	for i, not := range r.not {
+		if not.Match(line) {
+			explanations = append(explanations, Explanation{
+				Pattern:  "not " + r.notPatterns[i],
+				Mismatch: pattern.Mismatch{Reason: "the excluded pattern matches the line"},
+			})
+		}
+	}
+	return explanations
+}
@@ -0,0 +1,100 @@
+package patt_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"patt"
+)
+
+type containsMatcher string
+
+func (c containsMatcher) Match(line []byte) bool {
+	return bytes.Contains(line, []byte(c))
+}
+
+func TestBooleanMatchers(t *testing.T) {
+	a, b := containsMatcher("a"), containsMatcher("b")
+	tests := []struct {
+		name    string
+		matcher patt.LinesMatcher
+		want    []bool
+	}{
+		{name: "and", matcher: patt.And(a, b), want: []bool{false, false, false, true}},
+		{name: "or", matcher: patt.Or(a, b), want: []bool{false, true, true, true}},
+		{name: "not", matcher: patt.Not(a), want: []bool{true, false, true, false}},
+		{name: "and not", matcher: patt.And(a, patt.Not(b)), want: []bool{false, true, false, false}},
+		{name: "empty and", matcher: patt.And(), want: []bool{true, true, true, true}},
+		{name: "empty or", matcher: patt.Or(), want: []bool{false, false, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, line := range []string{"x", "a", "b", "ab"} {
+				if got := tt.matcher.Match([]byte(line)); got != tt.want[i] {
+					t.Errorf("Match(%q) = %v, want %v", line, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBooleanReplacer(t *testing.T) {
+	r, err := patt.NewBooleanReplacer(
+		[]string{"<level> <message>"},
+		[]string{"<_> status=<status> <_>"},
+		[]string{"<_> /health <_>"},
+		"<level> <status>",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line     string
+		want     string
+		captures patt.Captures
+	}{
+		{line: "error GET /health status=500 1ms"},
+		{line: "error GET /api 1ms"},
+		{
+			line: "error GET /api status=500 1ms",
+			want: "error 500",
+			captures: patt.Captures{
+				Names:  []string{"level", "message", "status"},
+				Values: [][]byte{[]byte("error"), []byte("GET /api status=500 1ms"), []byte("500")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			line := []byte(tt.line)
+			if !r.Match(line) {
+				if tt.want != "" {
+					t.Fatalf("Match(%q) = false", tt.line)
+				}
+				if len(r.Explain(line)) == 0 {
+					t.Errorf("Explain(%q) is empty", tt.line)
+				}
+				return
+			}
+			if tt.want == "" {
+				t.Fatalf("Match(%q) = true", tt.line)
+			}
+			if got := string(r.Replace(line)); got != tt.want {
+				t.Errorf("Replace() = %q, want %q", got, tt.want)
+			}
+			if got := r.Captures(line); !reflect.DeepEqual(got, tt.captures) {
+				t.Errorf("Captures() = %v, want %v", got, tt.captures)
+			}
+		})
+	}
+}
+
+func TestNewBooleanReplacer_MissingCapture(t *testing.T) {
+	_, err := patt.NewBooleanReplacer([]string{"<level> <message>"}, []string{"<_> status=<status> <_>"}, nil, "<level> <latency>")
+	if err == nil {
+		t.Error("NewBooleanReplacer() should fail for a capture missing from the patterns")
+	}
+}
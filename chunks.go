@@ -0,0 +1,157 @@
+package patt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"sync"
+)
+
+// DefaultChunkSize is the approximate size of the chunks a file is split into.
+const DefaultChunkSize = 64 * 1024 * 1024
+
+// ChunkedFileProcessor processes a single file on multiple goroutines, by
+// splitting it into chunks aligned to line boundaries. The output of the
+// chunks is written in order, as if the file was processed sequentially.
+type ChunkedFileProcessor struct {
+	name       string
+	processor  LineProcessor
+	writer     io.Writer
+	numWorkers int
+	chunkSize  int64
+}
+
+// NewChunkedFileProcessor creates a processor for the file name. Each worker
+// uses its own clone of the processor when it implements Clone() LineProcessor.
+// The file is read as it is, it must not be compressed.
+func NewChunkedFileProcessor(name string, processor LineProcessor, writer io.Writer, numWorkers int, chunkSize int64) *ChunkedFileProcessor {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &ChunkedFileProcessor{
+		name:       name,
+		processor:  processor,
+		writer:     writer,
+		numWorkers: max(numWorkers, 1),
+		chunkSize:  chunkSize,
+	}
+}
+
+func (cp *ChunkedFileProcessor) Process(ctx context.Context) (bool, error) {
+	f, err := os.Open(cp.name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() {
+		return false, fmt.Errorf("cannot split %s: not a regular file", cp.name)
+	}
+	return processConcurrently(ctx, cp.chunks(f, info.Size()), cp.processor, cp.writer, cp.numWorkers, false, nil)
+}
+
+// chunks splits the first size bytes of f in chunks of about chunkSize bytes,
+// each ending after a newline except the last one. The lines of each chunk
+// are counted while it is processed, to number the lines of the next ones.
+func (cp *ChunkedFileProcessor) chunks(f *os.File, size int64) iter.Seq[source] {
+	return func(yield func(source) bool) {
+		var prev *chunkLines
+		for start := int64(0); start < size; {
+			end, err := nextLineStart(f, start+cp.chunkSize, size)
+			if err != nil {
+				yield(source{name: cp.name, open: func() (io.ReadCloser, error) {
+					return nil, fmt.Errorf("cannot split %s: %w", cp.name, err)
+				}})
+				return
+			}
+			lines := &chunkLines{prev: prev, done: make(chan struct{})}
+			section := io.NewSectionReader(f, start, end-start)
+			open := func() (io.ReadCloser, error) {
+				return &lineCountingReader{r: section, lines: lines}, nil
+			}
+			if !yield(source{name: cp.name, firstLine: lines.firstLine, open: open}) {
+				return
+			}
+			start, prev = end, lines
+		}
+	}
+}
+
+// chunkLines is the number of lines of a chunk, known once it has been read.
+type chunkLines struct {
+	prev  *chunkLines
+	count int
+	done  chan struct{}
+
+	once  sync.Once
+	first int
+}
+
+// firstLine returns the number of the first line of the chunk in the file,
+// waiting for the previous chunks to be read. It is only called to report a
+// line, and the previous chunks are processed before, so they are read.
+func (c *chunkLines) firstLine() int {
+	c.once.Do(func() {
+		c.first = 1
+		if c.prev != nil {
+			<-c.prev.done
+			c.first = c.prev.firstLine() + c.prev.count
+		}
+	})
+	return c.first
+}
+
+// lineCountingReader counts the newlines of a chunk as it is read, and
+// reports their number when closed.
+type lineCountingReader struct {
+	r      io.Reader
+	lines  *chunkLines
+	count  int
+	closed bool
+}
+
+func (r *lineCountingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.count += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
+
+func (r *lineCountingReader) Close() error {
+	if !r.closed {
+		r.closed = true
+		r.lines.count = r.count
+		close(r.lines.done)
+	}
+	return nil
+}
+
+// nextLineStart returns the offset of the first line starting at or after
+// off, or size if there is none.
+func nextLineStart(r io.ReaderAt, off, size int64) (int64, error) {
+	if off >= size {
+		return size, nil
+	}
+	// The line starts after the newline preceding off, or further.
+	off--
+	buf := make([]byte, 4096)
+	for off < size {
+		n, err := r.ReadAt(buf[:min(int64(len(buf)), size-off)], off)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return off + int64(i) + 1, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if n == 0 {
+			break
+		}
+		off += int64(n)
+	}
+	return size, nil
+}
@@ -0,0 +1,160 @@
+package patt_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"patt"
+)
+
+func TestChunkedFileProcessor(t *testing.T) {
+	content, err := os.ReadFile("testdata/Apache_2k.log")
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	noTrailingNewline := filepath.Join(t.TempDir(), "no_newline.log")
+	writeFile(t, noTrailingNewline, "foo 1\nbaz 2\nfoo 3")
+
+	tests := []struct {
+		name      string
+		file      string
+		chunkSize int64
+		patterns  []string
+	}{
+		{
+			name:      "chunks smaller than lines",
+			file:      "testdata/Apache_2k.log",
+			chunkSize: 7,
+			patterns:  []string{"[<day> <_>] [error] <message>", "[<day> <_>] [notice] <message>"},
+		},
+		{
+			name:      "chunks of many lines",
+			file:      "testdata/Apache_2k.log",
+			chunkSize: int64(len(content) / 10),
+			patterns:  []string{"[<day> <_>] [error] <message>", "[<day> <_>] [notice] <message>"},
+		},
+		{
+			name:      "single chunk",
+			file:      "testdata/Apache_2k.log",
+			chunkSize: int64(len(content) * 2),
+			patterns:  []string{"[<day> <_>] [error] <message>"},
+		},
+		{
+			name:      "no trailing newline",
+			file:      noTrailingNewline,
+			chunkSize: 4,
+			patterns:  []string{"foo <message>", "baz <message>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replacer, err := patt.NewMultiReplacer(tt.patterns, "<message>")
+			if err != nil {
+				t.Fatalf("NewMultiReplacer() error = %v", err)
+			}
+			processor := patt.NewLineProcessor(replacer, true)
+
+			input, err := os.Open(tt.file)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer input.Close()
+			var expected bytes.Buffer
+			if _, err := processor.Process(context.Background(), input, &expected); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			var got bytes.Buffer
+			chunked := patt.NewChunkedFileProcessor(tt.file, processor, &got, 4, tt.chunkSize)
+			matched, err := chunked.Process(t.Context())
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if !matched {
+				t.Errorf("Process() matched = false, want true")
+			}
+			if got.String() != expected.String() {
+				t.Errorf("chunked output differs from sequential output")
+			}
+		})
+	}
+}
+
+func TestChunkedFileProcessor_LongLines(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	writeFile(t, name, "one\ntwo\nthree\nfour\n0123456789\nsix\n0123456789\n")
+
+	tests := []struct {
+		name             string
+		policy           patt.LongLinePolicy
+		expectedWarnings string
+		expectedErr      string
+	}{
+		{
+			name:             "skip",
+			policy:           patt.LongLinesSkip,
+			expectedWarnings: "patt: skipping line 5, longer than 8 bytes\npatt: skipping line 7, longer than 8 bytes\n",
+		},
+		{
+			name:        "fail",
+			policy:      patt.LongLinesFail,
+			expectedErr: "line 5 is longer than the maximum line length of 8 bytes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings bytes.Buffer
+			processor := patt.NewLineProcessor(makeMatcher(t, "<_>"), false, patt.WithMaxLineLength(8, tt.policy), patt.WithWarnings(&warnings))
+			// Chunks of two lines, except the last one.
+			chunked := patt.NewChunkedFileProcessor(name, processor, io.Discard, 1, 5)
+			_, err := chunked.Process(t.Context())
+			if tt.expectedErr != "" && (err == nil || err.Error() != tt.expectedErr) {
+				t.Errorf("Process() error = %v, want %q", err, tt.expectedErr)
+			}
+			if tt.expectedErr == "" && err != nil {
+				t.Errorf("Process() error = %v", err)
+			}
+			if warnings.String() != tt.expectedWarnings {
+				t.Errorf("expected warnings %q but got %q", tt.expectedWarnings, warnings.String())
+			}
+		})
+	}
+}
+
+func TestChunkedFileProcessor_FileNotFound(t *testing.T) {
+	processor := patt.NewLineProcessor(makeMatcher(t, "<_>"), false)
+	chunked := patt.NewChunkedFileProcessor("testdata/non-existent.log", processor, &bytes.Buffer{}, 4, 0)
+	if _, err := chunked.Process(t.Context()); err == nil {
+		t.Error("expected error for non-existent file, got nil")
+	}
+}
+
+func TestRunCLI_SplitPipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	defer r.Close()
+	name := fmt.Sprintf("/dev/fd/%d", r.Fd())
+	if _, err := os.Stat(name); err != nil {
+		t.Skipf("pipe has no file name: %v", err)
+	}
+	go func() {
+		w.WriteString("a 1\nb 2\na 3\n")
+		w.Close()
+	}()
+
+	stdout := &bytes.Buffer{}
+	err = patt.RunCLI(t.Context(), []string{"patt", "--split", "a <x>", "--", name}, nil, stdout, io.Discard)
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	if want := "a 1\na 3\n"; stdout.String() != want {
+		t.Errorf("expected output %q, got %q", want, stdout.String())
+	}
+}
@@ -8,11 +8,65 @@ import (
 	"io"
 	"os"
 	"runtime/pprof"
-	"slices"
+	"strings"
+	"time"
 )
 
-func RunCLI(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+// ErrNoMatch is returned by RunCLI when no line matched.
+var ErrNoMatch = errors.New("no match")
+
+// Exit statuses, compatible with grep.
+const (
+	ExitMatch   = 0
+	ExitNoMatch = 1
+	ExitError   = 2
+)
+
+// ExitCode returns the exit status for the error returned by RunCLI.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitMatch
+	case errors.Is(err, ErrNoMatch):
+		return ExitNoMatch
+	}
+	return ExitError
+}
+
+// RunCLI runs patt with the command-line arguments args. Errors reading some
+// of the input files are written to stderr while the others are processed.
+//
+// The subcommand `patt formats [name...]` lists the bundled log formats, and
+// `patt detect [file]` the known formats matching the first lines of the file.
+// `patt learn [file]` prints a pattern matching the example lines of the file,
+// `patt cluster [file...]` the templates of the lines of the files, and
+// `patt explain pattern [line...]` why the lines do not match the pattern.
+// A first argument -- searches for the patterns that follow, even named like
+// a subcommand, and the input files are given after a second --.
+func RunCLI(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) > 1 {
+		switch args[1] {
+		case "--":
+			args = args[1:]
+		case "formats":
+			return runFormats(args[2:], stdout)
+		case "detect":
+			return runDetect(ctx, args[2:], stdin, stdout)
+		case "learn":
+			return runLearn(ctx, args[2:], stdin, stdout)
+		case "cluster":
+			return runCluster(ctx, args[2:], stdin, stdout)
+		case "explain":
+			return runExplain(ctx, args[2:], stdin, stdout)
+		}
+	}
+
 	params, err := ParseCLIParams(args[1:])
+	var helpErr *HelpError
+	if errors.As(err, &helpErr) {
+		_, err := io.WriteString(stdout, helpErr.Usage)
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("bad parameters: %w", err)
 	}
@@ -29,65 +83,348 @@ func RunCLI(ctx context.Context, args []string, stdin io.Reader, stdout io.Write
 		defer pprof.StopCPUProfile()
 	}
 
+	if err := loadPatternsFiles(&params); err != nil {
+		return fmt.Errorf("cannot read patterns file: %w", err)
+	}
+	if params.Use != "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("cannot find pattern library: %w", err)
+		}
+		library, err := ReadPatternLibrary(LibraryPaths(wd)...)
+		if err != nil {
+			return fmt.Errorf("cannot read pattern library: %w", err)
+		}
+		if err := usePatterns(&params, library); err != nil {
+			return err
+		}
+	}
+
 	replacer, err := replacer(params)
 	if err != nil {
 		return fmt.Errorf("cannot parse template: %w", err)
 	}
 
-	processor := NewLineProcessor(replacer, params.Keep)
+	aggregator, err := aggregator(params)
+	if err != nil {
+		return fmt.Errorf("cannot configure aggregation: %w", err)
+	}
+	opts := []LineProcessorOption{WithWarnings(stderr)}
+	if params.MaxLineLength > 0 || params.LongLines != "" {
+		policy := LongLinesFail
+		if params.LongLines != "" {
+			policy, _ = ParseLongLinePolicy(params.LongLines)
+		}
+		opts = append(opts, WithMaxLineLength(params.MaxLineLength, policy))
+	}
+	stopReports := func() {}
+	if aggregator != nil {
+		// Aggregators are shared by the workers processing files concurrently.
+		aggregator = &syncAggregator{Aggregator: aggregator}
+		if params.Follow && params.ReportInterval > 0 {
+			stopReports = startReports(ctx, aggregator, stdout, params.ReportInterval)
+			defer stopReports()
+		}
+		opts = append(opts, WithAggregator(aggregator))
+	}
+	if params.Follow {
+		opts = append(opts, WithLineBuffering())
+	}
+	if params.AllMatches {
+		opts = append(opts, WithAllMatches())
+	}
+	if params.Where != "" {
+		where, err := ParseWhere(params.Where)
+		if err != nil {
+			return fmt.Errorf("bad parameters: %w", err)
+		}
+		for _, name := range where.Names() {
+			if err := checkCapture(params.capturePatterns(), params.And, name); err != nil {
+				return fmt.Errorf("cannot filter captures: %w", err)
+			}
+		}
+		opts = append(opts, WithWhere(where))
+	}
+	if params.Explain > 0 {
+		opts = append(opts, WithExplain(params.Explain, stderr))
+	}
+	var coverage *Coverage
+	if params.Coverage {
+		coverage = NewCoverage(params.SearchPatterns)
+		opts = append(opts, WithCoverage(coverage))
+	}
+	processor := NewLineProcessor(replacer, params.Keep, opts...)
+
+	jobs := params.Jobs
+	if jobs == 0 {
+		jobs = defaultJobs
+	}
 
 	var match bool
+	var failedFiles int
 	if len(params.InputFiles) == 0 {
 		match, err = processor.Process(ctx, io.NopCloser(stdin), stdout)
 		if err != nil {
 			return fmt.Errorf("error matching lines: %w", err)
 		}
-	} else if len(params.InputFiles) == 1 {
-		fileOpener := &BufferedFileOpener{}
-		rc, err := fileOpener.Open(params.InputFiles[0])
+	} else if params.Split && splittable(params) {
+		chunkedProcessor := NewChunkedFileProcessor(params.InputFiles[0], processor, stdout, jobs, params.ChunkSize)
+		match, err = chunkedProcessor.Process(ctx)
+		if err != nil {
+			return fmt.Errorf("error matching file: %w", err)
+		}
+	} else if len(params.InputFiles) == 1 && (params.Follow || !isDir(params.InputFiles[0])) {
+		var rc io.ReadCloser
+		if params.Follow {
+			rc, err = NewFollowReader(ctx, params.InputFiles[0], defaultPollInterval, params.FromStart)
+		} else {
+			fileOpener := &BufferedFileOpener{Raw: params.NoDecompress}
+			rc, err = fileOpener.Open(params.InputFiles[0])
+		}
 		if err != nil {
 			return fmt.Errorf("cannot open input file: %w", err)
 		}
 		defer rc.Close()
 
-		match, err = processor.Process(ctx, rc, stdout)
+		match, err = processFrom(ctx, processor, params.InputFiles[0], nil, rc, stdout)
 		if err != nil {
 			return fmt.Errorf("error matching file: %w", err)
 		}
 	} else {
+		filesOpts := []FilesProcessorOption{WithErrorWriter(stderr)}
+		if params.FailFast {
+			filesOpts = append(filesOpts, WithFailFast())
+		}
+		if params.Unordered {
+			filesOpts = append(filesOpts, WithUnorderedOutput())
+		}
 		filesProcessor := NewFilesProcessor(
-			slices.Values(params.InputFiles),
+			WalkInputs(params.InputFiles, params.Walk),
 			processor,
 			stdout,
-			&BufferedFileOpener{},
-			4,
+			&BufferedFileOpener{Raw: params.NoDecompress},
+			jobs,
+			filesOpts...,
 		)
 		match, err = filesProcessor.Process(ctx)
-		if err != nil {
+		var filesErr *FilesError
+		if errors.As(err, &filesErr) {
+			// Already reported, the report of the other files is still useful.
+			failedFiles = len(filesErr.Errs)
+		} else if err != nil {
 			return fmt.Errorf("error matching files: %w", err)
 		}
 	}
+	if aggregator != nil {
+		stopReports()
+		if err := reportAggregation(aggregator, stdout, stderr); err != nil {
+			return fmt.Errorf("cannot report aggregation: %w", err)
+		}
+	}
+	if stats, ok := replacer.(interface{ Stats() MatchStats }); ok && params.Stats {
+		if err := writeStats(stderr, params.SearchPatterns, stats.Stats()); err != nil {
+			return fmt.Errorf("cannot report stats: %w", err)
+		}
+	}
+	if coverage != nil {
+		if err := reportCoverage(coverage, params.CoverageFile, stderr); err != nil {
+			return fmt.Errorf("cannot report coverage: %w", err)
+		}
+	}
+	if failedFiles > 0 {
+		return fmt.Errorf("%d input files could not be processed", failedFiles)
+	}
 	if !match {
-		return fmt.Errorf("no match")
+		return ErrNoMatch
 	}
 
 	return nil
 }
 
 func replacer(params CLIParams) (LineReplacer, error) {
+	// A MultiReplacer is needed for its statistics, its matching patterns and
+	// its line filters even with a single pattern.
+	filters, err := params.LineFilters()
+	if err != nil {
+		return nil, err
+	}
+	multi := len(params.SearchPatterns) > 1 || params.Stats || params.AllMatches || len(filters) > 0
+	var opts []MultiReplacerOption
+	if len(filters) > 0 {
+		opts = append(opts, WithLineFilters(filters...))
+	}
+	if params.Reorder {
+		opts = append(opts, WithAdaptiveOrder())
+	}
+	if params.AllMatches {
+		opts = append(opts, WithAllPatterns())
+	}
 	switch {
-	case params.ReplaceTemplate == "":
+	case params.LineFormat != "":
+		m, err := NewMultiFilter(params.SearchPatterns, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return NewLineFormatReplacer(m, params.LineFormat)
+	case len(params.And) > 0 || len(params.Not) > 0:
+		return NewBooleanReplacer(params.SearchPatterns, params.And, params.Not, params.ReplaceTemplate, opts...)
+	case params.ReplaceTemplate == "" && len(params.SearchPatterns) == 1 && !multi:
 		return NewFilter(params.SearchPatterns[0])
-	case len(params.SearchPatterns) == 1:
+	case params.ReplaceTemplate == "" && len(params.SearchPatterns) > 0:
+		return NewMultiFilter(params.SearchPatterns, opts...)
+	case len(params.SearchPatterns) == 1 && !multi:
 		return NewReplacer(params.SearchPatterns[0], params.ReplaceTemplate)
-	case len(params.SearchPatterns) > 1:
-		return NewMultiReplacer(params.SearchPatterns, params.ReplaceTemplate)
+	case len(params.SearchPatterns) > 0:
+		return NewMultiReplacer(params.SearchPatterns, params.ReplaceTemplate, opts...)
 	}
 	return nil, errors.New("invalid parameters, cannot initialize replacer")
 }
 
+// splittable reports whether the single input file can be split in chunks,
+// which requires a regular file read as it is. Pipes and other files that
+// cannot be seeked are streamed instead: their size is unknown, and checking
+// whether they are compressed would consume their first bytes.
+func splittable(params CLIParams) bool {
+	if len(params.InputFiles) != 1 || !isSeekableFile(params.InputFiles[0]) {
+		return false
+	}
+	if params.NoDecompress {
+		return true
+	}
+	compressed, err := isCompressedFile(params.InputFiles[0])
+	return err == nil && !compressed
+}
+
+// isSeekableFile reports whether name is a regular file that can be seeked.
+// The file is only opened once known to be regular, since opening a FIFO
+// waits for a writer and closing it would break the pipe.
+func isSeekableFile(name string) bool {
+	info, err := os.Stat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, err = f.Seek(0, io.SeekEnd)
+	return err == nil
+}
+
+const (
+	defaultJobs = 4
+
+	defaultBucket = time.Minute
+	defaultTopK   = 10
+
+	defaultDistinctLimit = 100000
+)
+
+// aggregator returns the aggregations requested by params, or nil when matching
+// lines should be printed.
+func aggregator(params CLIParams) (Aggregator, error) {
+	var aggregators multiAggregator
+	if params.Histogram != "" {
+		if err := checkCapture(params.capturePatterns(), params.And, params.Histogram); err != nil {
+			return nil, err
+		}
+		bucket := params.Bucket
+		if bucket == 0 {
+			bucket = defaultBucket
+		}
+		h, err := NewTimeHistogram(params.Histogram, params.TimeLayout, bucket, params.Sparkline)
+		if err != nil {
+			return nil, err
+		}
+		aggregators = append(aggregators, h)
+	}
+	if params.Top != "" {
+		if err := checkCapture(params.capturePatterns(), params.And, params.Top); err != nil {
+			return nil, err
+		}
+		k := params.TopK
+		if k == 0 {
+			k = defaultTopK
+		}
+		top, err := NewTopK(params.Top, k)
+		if err != nil {
+			return nil, err
+		}
+		aggregators = append(aggregators, top)
+	}
+	if params.Distinct != "" {
+		if err := checkCapture(params.capturePatterns(), params.And, params.Distinct); err != nil {
+			return nil, err
+		}
+		limit := params.DistinctLimit
+		if limit == 0 {
+			limit = defaultDistinctLimit
+		}
+		distinct, err := NewDistinct(params.Distinct, limit)
+		if err != nil {
+			return nil, err
+		}
+		aggregators = append(aggregators, distinct)
+	}
+	if params.Cardinality != "" {
+		if err := checkCapture(params.capturePatterns(), params.And, params.Cardinality); err != nil {
+			return nil, err
+		}
+		aggregators = append(aggregators, NewCardinality(params.Cardinality))
+	}
+	switch len(aggregators) {
+	case 0:
+		return nil, nil
+	case 1:
+		return aggregators[0], nil
+	}
+	return aggregators, nil
+}
+
+// writeStats writes the number of lines matched by each pattern, and the
+// average number of patterns tried per line.
+func writeStats(w io.Writer, patterns []string, stats MatchStats) error {
+	var b strings.Builder
+	perLine := 0.0
+	if stats.Lines > 0 {
+		perLine = float64(stats.Tries) / float64(stats.Lines)
+	}
+	fmt.Fprintf(&b, "stats: %d lines, %.2f patterns tried per line\n", stats.Lines, perLine)
+	for i, p := range patterns {
+		percent := 0.0
+		if stats.Lines > 0 {
+			percent = 100 * float64(stats.Hits[i]) / float64(stats.Lines)
+		}
+		fmt.Fprintf(&b, "%d\t%.1f%%\t%s\n", stats.Hits[i], percent, p)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// reportCoverage writes the coverage report to the file name, or to stderr
+// without a name.
+func reportCoverage(coverage *Coverage, name string, stderr io.Writer) error {
+	if name == "" {
+		return coverage.Report(stderr)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := coverage.Report(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// BufferedFileOpener opens files for buffered reading. Unless Raw is set,
+// gzip, bzip2 and zlib compressed files are detected by their magic bytes and
+// transparently decompressed.
 type BufferedFileOpener struct {
 	BufSize int
+	Raw     bool
 }
 
 func (ffo *BufferedFileOpener) Open(name string) (io.ReadCloser, error) {
@@ -96,16 +433,26 @@ func (ffo *BufferedFileOpener) Open(name string) (io.ReadCloser, error) {
 		return nil, err
 	}
 
-	if ffo.BufSize <= 0 {
-		ffo.BufSize = 4 * 1024 * 1024 // default 4 MB
+	bufSize := ffo.BufSize
+	if bufSize <= 0 {
+		bufSize = 4 * 1024 * 1024 // default 4 MB
+	}
+	br := bufio.NewReaderSize(f, bufSize)
+
+	var r io.Reader = br
+	if !ffo.Raw {
+		r, err = decompress(br)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
 	}
-	br := bufio.NewReaderSize(f, ffo.BufSize)
 
 	return struct {
 		io.Reader
 		io.Closer
 	}{
-		Reader: br,
+		Reader: r,
 		Closer: f,
 	}, nil
 }
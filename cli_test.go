@@ -3,7 +3,9 @@ package patt_test
 import (
 	"bytes"
 	"context"
+	"io"
 	"patt"
+	"strings"
 	"testing"
 )
 
@@ -33,6 +35,76 @@ func TestRunCLI(t *testing.T) {
 			stdin:     "something match\n",
 			expectErr: true,
 		},
+		{
+			name:      "patterns file with template",
+			args:      []string{"patt", "--patterns-file", "testdata/apache_levels.patterns", "--", "testdata/Apache_3.log.gz"},
+			expectOut: "Sun Dec 04 04:47:44 2005: workerEnv.init() ok /etc/httpd/conf/workers2.properties\nSun Dec 04 04:47:44 2005: mod_jk child workerEnv in error state 6\nSun Dec 04 04:51:08 2005: jk2_init() Found child 6725 in scoreboard slot 10\n",
+		},
+		{
+			name:      "patterns file with template argument",
+			args:      []string{"patt", "--patterns-file", "testdata/apache_levels.patterns", "<message>", "--", "testdata/Apache_3.log.gz"},
+			expectOut: "workerEnv.init() ok /etc/httpd/conf/workers2.properties\nmod_jk child workerEnv in error state 6\njk2_init() Found child 6725 in scoreboard slot 10\n",
+		},
+		{
+			name:      "all matches",
+			args:      []string{"patt", "--all-matches", "[<_>] [error] <message>", "[<_>] <_> child <message>", "<message>", "--", "testdata/Apache_3.log.gz"},
+			expectOut: "0\tmod_jk child workerEnv in error state 6\n1\tworkerEnv in error state 6\n1\t6725 in scoreboard slot 10\n",
+		},
+		{
+			name:      "all matches with keep",
+			args:      []string{"patt", "--all-matches", "-k", "[<_>] [error] <m>", "<_> child <m>", "<m>", "--", "testdata/Apache_3.log.gz"},
+			expectOut: "[Sun Dec 04 04:47:44 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n0\tmod_jk child workerEnv in error state 6\n1\tworkerEnv in error state 6\n1\t6725 in scoreboard slot 10\n",
+		},
+		{
+			name:      "and and not patterns",
+			args:      []string{"patt", "--and", "<_> slot <slot>", "--not", "<_> [error] <_>", "[<date>] [<level>] <_>", "<level> <date> <slot>", "--", "testdata/Apache_3.log.gz"},
+			expectOut: "notice Sun Dec 04 04:51:08 2005 10\n",
+		},
+		{
+			name:      "and pattern with keep",
+			args:      []string{"patt", "-k", "--and", "<_> child <_>", "[<date>] [notice] <_>", "<date>", "--", "testdata/Apache_3.log.gz"},
+			expectOut: "[Sun Dec 04 04:47:44 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n[Sun Dec 04 04:47:44 2005] [error] mod_jk child workerEnv in error state 6\nSun Dec 04 04:51:08 2005\n",
+		},
+		{
+			name:      "template capture missing from and patterns",
+			args:      []string{"patt", "--and", "<_> slot <slot>", "[<date>] <_>", "<date> <level>", "--", "testdata/Apache_3.log.gz"},
+			expectErr: true,
+		},
+		{
+			name:      "line filters",
+			args:      []string{"patt", "--contains", "[notice]", "--not-regex", `slot \d+$`, "[<date>] [<level>] <message>", "<message>", "--", "testdata/Apache_3.log.gz"},
+			expectOut: "workerEnv.init() ok /etc/httpd/conf/workers2.properties\n",
+		},
+		{
+			name:      "invalid regex line filter",
+			args:      []string{"patt", "--regex", "(", "<_>"},
+			expectErr: true,
+		},
+		{
+			name:      "where condition",
+			args:      []string{"patt", "--where", `level = "error" or message =~ ".* slot (1[0-9])"`, "[<date>] [<level>] <message>", "<message>", "--", "testdata/Apache_3.log.gz"},
+			expectOut: "mod_jk child workerEnv in error state 6\njk2_init() Found child 6725 in scoreboard slot 10\n",
+		},
+		{
+			name:      "where condition with keep",
+			args:      []string{"patt", "-k", "--where", "child > 1000", "[<_>] [<_>] <_> child <child> <_>", "<child>", "--", "testdata/Apache_3.log.gz"},
+			expectOut: "[Sun Dec 04 04:47:44 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n[Sun Dec 04 04:47:44 2005] [error] mod_jk child workerEnv in error state 6\n6725\n",
+		},
+		{
+			name:      "where condition on a missing capture",
+			args:      []string{"patt", "--where", "slot > 1", "[<date>] <_>", "--", "testdata/Apache_3.log.gz"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid where condition",
+			args:      []string{"patt", "--where", "slot >", "<_> <slot>"},
+			expectErr: true,
+		},
+		{
+			name:      "missing patterns file",
+			args:      []string{"patt", "--patterns-file", "testdata/non-existent.patterns"},
+			expectErr: true,
+		},
 		{
 			name:      "invalid search pattern",
 			args:      []string{"patt", "something <placeholder><wrong>", "found <placeholder>!"},
@@ -94,6 +166,97 @@ func TestRunCLI(t *testing.T) {
 			expectOut: "[Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n" +
 				"[Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n",
 		},
+		{
+			name: "histogram of a timestamp capture",
+			args: []string{"patt", "--histogram", "ts", "[<ts>] [error] <_>"},
+			stdin: "[Sun Dec 04 04:47:44 2005] [error] mod_jk child workerEnv in error state 6\n" +
+				"[Sun Dec 04 04:47:50 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n" +
+				"[Sun Dec 04 04:49:01 2005] [error] mod_jk child workerEnv in error state 7\n",
+			expectOut: "2005-12-04 04:47:00\t1\n" +
+				"2005-12-04 04:48:00\t0\n" +
+				"2005-12-04 04:49:00\t1\n",
+		},
+		{
+			name: "replace from files concurrently, multiple search patterns",
+			args: []string{"patt", "-j", "3",
+				"[Sun Dec 04 04:51:08 2005] <something>",
+				"[Sun Dec 04 04:51:37 2005] <something>",
+				"Found: <something>", "--", "testdata/Apache_2k.log", "testdata/Apache_3.log.gz", "testdata/Apache_2k.log"},
+			expectOut: "Found: [notice] jk2_init() Found child 6725 in scoreboard slot 10\n" +
+				"Found: [notice] jk2_init() Found child 6736 in scoreboard slot 10\n" +
+				"Found: [notice] jk2_init() Found child 6725 in scoreboard slot 10\n" +
+				"Found: [notice] jk2_init() Found child 6725 in scoreboard slot 10\n" +
+				"Found: [notice] jk2_init() Found child 6736 in scoreboard slot 10\n",
+		},
+		{
+			name:      "search from file split in chunks",
+			args:      []string{"patt", "--split", "--chunk-size", "1000", "[Sun Dec 04 04:51:<_>] <_>", "--", "testdata/Apache_2k.log"},
+			expectOut: "[Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n" +
+				"[Sun Dec 04 04:51:09 2005] [notice] jk2_init() Found child 6726 in scoreboard slot 8\n" +
+				"[Sun Dec 04 04:51:09 2005] [notice] jk2_init() Found child 6728 in scoreboard slot 6\n" +
+				"[Sun Dec 04 04:51:14 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n" +
+				"[Sun Dec 04 04:51:14 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n" +
+				"[Sun Dec 04 04:51:14 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n" +
+				"[Sun Dec 04 04:51:18 2005] [error] mod_jk child workerEnv in error state 6\n" +
+				"[Sun Dec 04 04:51:18 2005] [error] mod_jk child workerEnv in error state 6\n" +
+				"[Sun Dec 04 04:51:18 2005] [error] mod_jk child workerEnv in error state 6\n" +
+				"[Sun Dec 04 04:51:37 2005] [notice] jk2_init() Found child 6736 in scoreboard slot 10\n" +
+				"[Sun Dec 04 04:51:38 2005] [notice] jk2_init() Found child 6733 in scoreboard slot 7\n" +
+				"[Sun Dec 04 04:51:38 2005] [notice] jk2_init() Found child 6734 in scoreboard slot 9\n" +
+				"[Sun Dec 04 04:51:52 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n" +
+				"[Sun Dec 04 04:51:52 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n" +
+				"[Sun Dec 04 04:51:55 2005] [error] mod_jk child workerEnv in error state 6\n",
+		},
+		{
+			name:      "search from compressed files",
+			args:      []string{"patt", "[Sun Dec 04 04:51:08 2005] <_>", "--", "testdata/Apache_3.log.gz", "testdata/Apache_3.log.bz2"},
+			expectOut: "[Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n" +
+				"[Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n",
+		},
+		{
+			name:      "search compressed file without decompression",
+			args:      []string{"patt", "--no-decompress", "[Sun Dec 04 04:51:08 2005] <_>", "--", "testdata/Apache_3.log.gz"},
+			expectErr: true,
+		},
+		{
+			name:      "search from directory",
+			args:      []string{"patt", "--include", "*.gz", "--include", "*.bz2", "[Sun Dec 04 04:51:08 2005] <_>", "--", "testdata"},
+			expectOut: "[Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n" +
+				"[Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n",
+		},
+		{
+			name:      "top values of a capture",
+			args:      []string{"patt", "--top", "msg", "--top-k", "2", "[<_>] [error] <msg>", "--", "testdata/Apache_2k.log"},
+			expectOut: "369\tmod_jk child workerEnv in error state 6\n101\tmod_jk child workerEnv in error state 7\n",
+		},
+		{
+			name:      "distinct values of a capture",
+			args:      []string{"patt", "--distinct", "user", "login <user>"},
+			stdin:     "login bob\nlogin alice\nlogout bob\nlogin bob\n",
+			expectOut: "bob\nalice\n",
+		},
+		{
+			name:      "cardinality of a capture",
+			args:      []string{"patt", "--cardinality", "user", "login <user>"},
+			stdin:     "login bob\nlogin alice\nlogout carol\nlogin bob\n",
+			expectOut: "2\n",
+		},
+		{
+			name:      "pattern named like a subcommand after --",
+			args:      []string{"patt", "--", "explain <what>", "<what>"},
+			stdin:     "explain this\nother\n",
+			expectOut: "this\n",
+		},
+		{
+			name:      "patterns after -- with input files",
+			args:      []string{"patt", "--", "[<_>] [error] <msg>", "<msg>", "--", "testdata/Apache_3.log.gz"},
+			expectOut: "mod_jk child workerEnv in error state 6\n",
+		},
+		{
+			name:      "histogram of an unknown capture",
+			args:      []string{"patt", "--histogram", "time", "[<ts>] [error] <_>"},
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -101,7 +264,7 @@ func TestRunCLI(t *testing.T) {
 			stdin := bytes.NewReader([]byte(tt.stdin))
 			stdout := &bytes.Buffer{}
 
-			err := patt.RunCLI(context.Background(), tt.args, stdin, stdout)
+			err := patt.RunCLI(context.Background(), tt.args, stdin, stdout, io.Discard)
 
 			if (err != nil) != tt.expectErr {
 				t.Errorf("expected error %v, got %v", tt.expectErr, err)
@@ -123,9 +286,90 @@ func BenchmarkRunCLI_Apache500MB(b *testing.B) {
 	}
 	for b.Loop() {
 		stdout := &bytes.Buffer{}
-		err := patt.RunCLI(context.Background(), args, nil, stdout)
+		err := patt.RunCLI(context.Background(), args, nil, stdout, io.Discard)
 		if err != nil {
 			b.Fatalf("RunCLI error: %v", err)
 		}
 	}
 }
+
+func TestRunCLI_ExitCode(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		stdin        string
+		expectCode   int
+		expectOut    string
+		expectStderr string
+	}{
+		{
+			name:       "match",
+			args:       []string{"patt", "something <_>"},
+			stdin:      "something match\n",
+			expectCode: patt.ExitMatch,
+			expectOut:  "something match\n",
+		},
+		{
+			name:       "no match",
+			args:       []string{"patt", "something <_>"},
+			stdin:      "other match\n",
+			expectCode: patt.ExitNoMatch,
+		},
+		{
+			name:       "bad parameters",
+			args:       []string{"patt"},
+			expectCode: patt.ExitError,
+		},
+		{
+			name:         "missing file among others",
+			args:         []string{"patt", "[Sun Dec 04 04:51:08 2005] <_>", "--", "testdata/non-existent.log", "testdata/Apache_3.log.gz"},
+			expectCode:   patt.ExitError,
+			expectOut:    "[Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n",
+			expectStderr: "patt: testdata/non-existent.log: open testdata/non-existent.log: no such file or directory\n",
+		},
+		{
+			name:         "missing file with fail fast",
+			args:         []string{"patt", "--fail-fast", "-j", "1", "[Sun Dec 04 04:51:08 2005] <_>", "--", "testdata/non-existent.log", "testdata/Apache_3.log.gz"},
+			expectCode:   patt.ExitError,
+			expectStderr: "",
+		},
+		{
+			name:         "distinct limit with other aggregations",
+			args:         []string{"patt", "--distinct", "user", "--distinct-limit", "1", "--cardinality", "user", "login <user>"},
+			stdin:        "login alice\nlogin bob\n",
+			expectCode:   patt.ExitMatch,
+			expectOut:    "alice\n2\n",
+			expectStderr: "patt: warning: capture 'user' has more than 1 distinct values, output is incomplete\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+			err := patt.RunCLI(context.Background(), tt.args, strings.NewReader(tt.stdin), stdout, stderr)
+
+			if code := patt.ExitCode(err); code != tt.expectCode {
+				t.Errorf("expected exit code %d, got %d (error %v)", tt.expectCode, code, err)
+			}
+			if stdout.String() != tt.expectOut {
+				t.Errorf("expected stdout %q, got %q", tt.expectOut, stdout.String())
+			}
+			if stderr.String() != tt.expectStderr {
+				t.Errorf("expected stderr %q, got %q", tt.expectStderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunCLI_Help(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	if err := patt.RunCLI(context.Background(), []string{"patt", "--help"}, nil, stdout, io.Discard); err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	for _, want := range []string{"patt explain pattern [line...]", `"patt -- explain -- file.log"`, "--all-matches"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("help does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package patt
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"patt/pattern"
+)
+
+const (
+	defaultClusterDepth      = 4
+	defaultClusterSimilarity = 0.4
+	clusterMaxChildren       = 100
+
+	clusterWildcard = "<*>"
+)
+
+// Cluster is a template of similar log lines, found by Drain.
+type Cluster struct {
+	// Tokens are the space separated words of the lines, a varying word
+	// being a wildcard.
+	Tokens []string
+	Count  int
+	// Sample is the first line of the cluster.
+	Sample string
+}
+
+// Pattern returns the template of the cluster, with the varying words
+// captured as <field1>, <field2>, ... Words that would not be parsed as
+// literals, such as <html>, are captured too.
+func (c Cluster) Pattern() (string, error) {
+	var b strings.Builder
+	captures := 0
+	for i, token := range c.Tokens {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if token == clusterWildcard || !isLiteralWord(token) {
+			captures++
+			fmt.Fprintf(&b, "<field%d>", captures)
+		} else {
+			b.WriteString(token)
+		}
+	}
+	p := b.String()
+	if p == "" {
+		// The cluster of empty lines.
+		return p, nil
+	}
+	m, err := pattern.New(p)
+	if err != nil {
+		return "", fmt.Errorf("invalid cluster pattern '%s': %w", p, err)
+	}
+	if len(m.Names()) != captures {
+		return "", fmt.Errorf("cluster pattern '%s' has literals parsed as captures", p)
+	}
+	return p, nil
+}
+
+// isLiteralWord reports whether the word is parsed as a literal in a pattern.
+func isLiteralWord(word string) bool {
+	if word == "" {
+		return true
+	}
+	literals, err := pattern.ParseLiterals(word)
+	return err == nil && len(literals) == 1 && string(literals[0]) == word
+}
+
+// Drain clusters log lines online with the Drain algorithm: lines are routed
+// by their number of words and first words in a tree of fixed depth, and join
+// the most similar cluster of the leaf, or start a new one.
+//
+// See "Drain: An Online Log Parsing Approach with Fixed Depth Tree", He et al.
+type Drain struct {
+	depth      int
+	similarity float64
+	root       *drainNode
+	clusters   []*Cluster
+}
+
+type drainNode struct {
+	children map[string]*drainNode
+	clusters []*Cluster
+}
+
+func newDrainNode() *drainNode {
+	return &drainNode{children: map[string]*drainNode{}}
+}
+
+// NewDrain creates a Drain routing lines by their first depth-2 words, and
+// joining a cluster when at least the similarity fraction of words are equal.
+func NewDrain(depth int, similarity float64) (*Drain, error) {
+	if depth < 3 {
+		return nil, fmt.Errorf("invalid depth %d, must be at least 3", depth)
+	}
+	if similarity <= 0 || similarity > 1 {
+		return nil, fmt.Errorf("invalid similarity %v, must be in (0, 1]", similarity)
+	}
+	return &Drain{depth: depth, similarity: similarity, root: newDrainNode()}, nil
+}
+
+// Add adds a line to its cluster.
+func (d *Drain) Add(line []byte) {
+	tokens := strings.Split(string(line), " ")
+	node := d.root.child(strconv.Itoa(len(tokens)))
+	for i := 0; i < d.depth-2 && i < len(tokens); i++ {
+		key := tokens[i]
+		if strings.ContainsAny(key, "0123456789") {
+			key = clusterWildcard
+		}
+		if _, ok := node.children[key]; !ok && len(node.children) >= clusterMaxChildren {
+			key = clusterWildcard
+		}
+		node = node.child(key)
+	}
+
+	var best *Cluster
+	bestSimilarity, bestWildcards := -1.0, -1
+	for _, c := range node.clusters {
+		similarity, wildcards := tokensSimilarity(c.Tokens, tokens)
+		if similarity > bestSimilarity || (similarity == bestSimilarity && wildcards > bestWildcards) {
+			best, bestSimilarity, bestWildcards = c, similarity, wildcards
+		}
+	}
+	if best == nil || bestSimilarity < d.similarity {
+		c := &Cluster{Tokens: tokens, Count: 1, Sample: string(line)}
+		node.clusters = append(node.clusters, c)
+		d.clusters = append(d.clusters, c)
+		return
+	}
+	best.Count++
+	for i, token := range tokens {
+		if best.Tokens[i] != token {
+			best.Tokens[i] = clusterWildcard
+		}
+	}
+}
+
+func (n *drainNode) child(key string) *drainNode {
+	c, ok := n.children[key]
+	if !ok {
+		c = newDrainNode()
+		n.children[key] = c
+	}
+	return c
+}
+
+// tokensSimilarity returns the fraction of tokens equal to the template
+// tokens, and the number of wildcards of the template.
+func tokensSimilarity(template, tokens []string) (float64, int) {
+	equal, wildcards := 0, 0
+	for i, t := range template {
+		switch {
+		case t == clusterWildcard:
+			wildcards++
+		case t == tokens[i]:
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(template)), wildcards
+}
+
+// Clusters returns the clusters, the largest first.
+func (d *Drain) Clusters() []Cluster {
+	clusters := make([]Cluster, len(d.clusters))
+	for i, c := range d.clusters {
+		clusters[i] = *c
+	}
+	slices.SortStableFunc(clusters, func(a, b Cluster) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return clusters
+}
+
+// runCluster implements `patt cluster [file...]`, which prints the templates
+// of the lines of the files or of stdin with their count and a sample line.
+func runCluster(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	var depth int
+	var similarity float64
+	var noDecompress bool
+	cmd := &cobra.Command{
+		Use:  "patt cluster [--depth n] [--similarity s] [file...]",
+		Args: cobra.ArbitraryArgs,
+	}
+	cmd.Flags().IntVar(&depth, "depth", defaultClusterDepth, "depth of the Drain tree, lines are routed by their first depth-2 words")
+	cmd.Flags().Float64Var(&similarity, "similarity", defaultClusterSimilarity, "minimum fraction of equal words of the lines of a cluster")
+	cmd.Flags().BoolVar(&noDecompress, "no-decompress", false, "do not decompress gzip, bzip2 and zlib input files")
+	if err := cmd.ParseFlags(args); err != nil {
+		return fmt.Errorf("bad parameters: %w", err)
+	}
+	drain, err := NewDrain(depth, similarity)
+	if err != nil {
+		return fmt.Errorf("bad parameters: %w", err)
+	}
+
+	add := func(r io.Reader) error {
+		lr := newLineReader(r, 0, LongLinesTruncate, nil)
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			line, err := lr.next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			drain.Add(line)
+		}
+	}
+	files := cmd.Flags().Args()
+	if len(files) == 0 {
+		if err := add(stdin); err != nil {
+			return fmt.Errorf("error reading lines: %w", err)
+		}
+	}
+	opener := &BufferedFileOpener{Raw: noDecompress}
+	for _, name := range files {
+		rc, err := opener.Open(name)
+		if err != nil {
+			return fmt.Errorf("cannot open input file: %w", err)
+		}
+		err = add(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", name, err)
+		}
+	}
+
+	clusters := drain.Clusters()
+	if len(clusters) == 0 {
+		return ErrNoMatch
+	}
+	for _, c := range clusters {
+		p, err := c.Pattern()
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(stdout, "%d\t%s\n  %s\n", c.Count, p, c.Sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
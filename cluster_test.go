@@ -0,0 +1,141 @@
+package patt_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+func TestDrain(t *testing.T) {
+	drain, err := patt.NewDrain(4, 0.4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"connected to 10.0.0.1 in 3ms",
+		"connected to 10.0.0.2 in 12ms",
+		"disk full on sda",
+		"connected to 10.0.0.1 in 4ms",
+		"login of user alice",
+		"login of user bob",
+	} {
+		drain.Add([]byte(line))
+	}
+
+	var got []string
+	for _, c := range drain.Clusters() {
+		p, err := c.Pattern()
+		if err != nil {
+			t.Fatalf("Pattern() error = %v", err)
+		}
+		got = append(got, p)
+	}
+	want := []string{
+		"connected to <field1> in <field2>",
+		"login of user <field1>",
+		"disk full on sda",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Clusters() = %q, want %q", got, want)
+	}
+	if c := drain.Clusters()[0]; c.Count != 3 || c.Sample != "connected to 10.0.0.1 in 3ms" {
+		t.Errorf("Clusters()[0] = %+v, want count 3 and the first line as sample", c)
+	}
+}
+
+func TestDrain_PatternsMatchLines(t *testing.T) {
+	drain, err := patt.NewDrain(4, 0.4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("testdata/Apache_2k.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		drain.Add(scanner.Bytes())
+	}
+
+	clusters := drain.Clusters()
+	patterns := make([]string, len(clusters))
+	total := 0
+	for i, c := range clusters {
+		if patterns[i], err = c.Pattern(); err != nil {
+			t.Fatalf("Pattern() error = %v", err)
+		}
+		total += c.Count
+	}
+	if total != 2000 {
+		t.Errorf("expected 2000 clustered lines, got %d", total)
+	}
+	filter, err := patt.NewMultiFilter(patterns)
+	if err != nil {
+		t.Fatalf("invalid cluster patterns: %v", err)
+	}
+	scanner = bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if !filter.Match(scanner.Bytes()) {
+			t.Errorf("line %q is not matched by the cluster patterns", scanner.Text())
+		}
+	}
+}
+
+func TestNewDrain_Errors(t *testing.T) {
+	if _, err := patt.NewDrain(2, 0.4); err == nil {
+		t.Error("expected an error for a depth of 2")
+	}
+	if _, err := patt.NewDrain(4, 0); err == nil {
+		t.Error("expected an error for a similarity of 0")
+	}
+}
+
+func TestRunCLI_Cluster(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	err := patt.RunCLI(context.Background(), []string{"patt", "cluster", "testdata/Apache_3.log.gz"}, strings.NewReader(""), stdout, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	want := "1\t[Sun Dec 04 04:47:44 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n" +
+		"  [Sun Dec 04 04:47:44 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n" +
+		"1\t[Sun Dec 04 04:47:44 2005] [error] mod_jk child workerEnv in error state 6\n" +
+		"  [Sun Dec 04 04:47:44 2005] [error] mod_jk child workerEnv in error state 6\n" +
+		"1\t[Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n" +
+		"  [Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n"
+	if stdout.String() != want {
+		t.Errorf("expected output %q, got %q", want, stdout.String())
+	}
+}
+
+func TestCluster_PatternCapturesTagWords(t *testing.T) {
+	drain, err := patt.NewDrain(4, 0.4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []string{"a <html> page 1", "a <html> page 2"}
+	for _, line := range lines {
+		drain.Add([]byte(line))
+	}
+
+	p, err := drain.Clusters()[0].Pattern()
+	if err != nil {
+		t.Fatalf("Pattern() error = %v", err)
+	}
+	if want := "a <field1> page <field2>"; p != want {
+		t.Errorf("Pattern() = %q, want %q", p, want)
+	}
+	filter, err := patt.NewFilter(p)
+	if err != nil {
+		t.Fatalf("NewFilter() error = %v", err)
+	}
+	for _, line := range lines {
+		if !filter.Match([]byte(line)) {
+			t.Errorf("line %q is not matched by %q", line, p)
+		}
+	}
+}
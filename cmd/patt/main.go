@@ -11,13 +11,15 @@ var version = "dev"
 
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
-	defer stop()
-	exitIfErr(patt.RunCLI(ctx, os.Args, os.Stdin, os.Stdout))
+	err := patt.RunCLI(ctx, os.Args, os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	exit(err)
 }
 
-func exitIfErr(err error) {
-	if err != nil {
-		_, _ = os.Stderr.WriteString(err.Error() + "\n")
-		os.Exit(1)
+func exit(err error) {
+	code := patt.ExitCode(err)
+	if code == patt.ExitError {
+		_, _ = os.Stderr.WriteString("patt: " + err.Error() + "\n")
 	}
+	os.Exit(code)
 }
\ No newline at end of file
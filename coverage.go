@@ -0,0 +1,104 @@
+package patt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// coverageClusters is the number of clusters of unmatched lines reported.
+const coverageClusters = 10
+
+// Coverage counts the lines matched by each pattern, and clusters the lines
+// matched by none. It is safe for concurrent use.
+type Coverage struct {
+	patterns  []string
+	hits      []atomic.Int64
+	matched   atomic.Int64
+	unmatched atomic.Int64
+
+	mu    sync.Mutex
+	drain *Drain
+}
+
+// NewCoverage creates a coverage report of the patterns, in the order they
+// are tried by the replacer.
+func NewCoverage(patterns []string) *Coverage {
+	drain, _ := NewDrain(defaultClusterDepth, defaultClusterSimilarity)
+	return &Coverage{
+		patterns: patterns,
+		hits:     make([]atomic.Int64, len(patterns)),
+		drain:    drain,
+	}
+}
+
+// WithCoverage counts the lines matched by each pattern of the replacer in c.
+func WithCoverage(c *Coverage) LineProcessorOption {
+	return func(p *lineProcessor) {
+		p.coverage = c
+	}
+}
+
+// addMatched counts a line matched by the patterns, several with --all-matches.
+func (c *Coverage) addMatched(patterns ...int) {
+	c.matched.Add(1)
+	for _, pattern := range patterns {
+		if pattern >= 0 && pattern < len(c.hits) {
+			c.hits[pattern].Add(1)
+		}
+	}
+}
+
+func (c *Coverage) addUnmatched(line []byte) {
+	c.unmatched.Add(1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drain.Add(line)
+}
+
+// matchedPattern returns the index of the pattern of r that matched the last
+// line. Replacers with several patterns implement MatchedPattern() int.
+func matchedPattern(r LinesMatcher) int {
+	if m, ok := r.(interface{ MatchedPattern() int }); ok {
+		return m.MatchedPattern()
+	}
+	return 0
+}
+
+// Report writes the number and percentage of lines matched by each pattern
+// and by none, followed by the most frequent templates of unmatched lines.
+func (c *Coverage) Report(w io.Writer) error {
+	var b strings.Builder
+	unmatched := c.unmatched.Load()
+	total := c.matched.Load() + unmatched
+	percent := func(n int64) float64 {
+		if total == 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(total)
+	}
+	fmt.Fprintf(&b, "coverage: %d lines, %d matched (%.1f%%)\n", total, total-unmatched, percent(total-unmatched))
+	for i, p := range c.patterns {
+		hits := c.hits[i].Load()
+		fmt.Fprintf(&b, "%d\t%.1f%%\t%s\n", hits, percent(hits), p)
+	}
+	fmt.Fprintf(&b, "%d\t%.1f%%\t(unmatched)\n", unmatched, percent(unmatched))
+
+	c.mu.Lock()
+	clusters := c.drain.Clusters()
+	c.mu.Unlock()
+	if len(clusters) > 0 {
+		b.WriteString("unmatched lines:\n")
+	}
+	for _, cluster := range clusters[:min(len(clusters), coverageClusters)] {
+		p, err := cluster.Pattern()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%d\t%s\n  %s\n", cluster.Count, p, cluster.Sample)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
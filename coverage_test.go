@@ -0,0 +1,77 @@
+package patt_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+func TestRunCLI_Coverage(t *testing.T) {
+	args := []string{"patt", "--coverage", "[<date>] [error] <message>", "[<date>] [crit] <message>", "<message>", "--", "testdata/Apache_3.log.gz"}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	err := patt.RunCLI(context.Background(), args, strings.NewReader(""), stdout, stderr)
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	if want := "mod_jk child workerEnv in error state 6\n"; stdout.String() != want {
+		t.Errorf("expected output %q, got %q", want, stdout.String())
+	}
+	want := "coverage: 3 lines, 1 matched (33.3%)\n" +
+		"1\t33.3%\t[<date>] [error] <message>\n" +
+		"0\t0.0%\t[<date>] [crit] <message>\n" +
+		"2\t66.7%\t(unmatched)\n" +
+		"unmatched lines:\n" +
+		"1\t[Sun Dec 04 04:47:44 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n" +
+		"  [Sun Dec 04 04:47:44 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties\n" +
+		"1\t[Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n" +
+		"  [Sun Dec 04 04:51:08 2005] [notice] jk2_init() Found child 6725 in scoreboard slot 10\n"
+	if stderr.String() != want {
+		t.Errorf("expected coverage\n%s\ngot\n%s", want, stderr.String())
+	}
+}
+
+func TestRunCLI_CoverageFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "coverage.txt")
+	args := []string{"patt", "--coverage-file", name, "-j", "2", "[<date>] [<level>] <message>", "--", "testdata/Apache_2k.log", "testdata/Apache_3.log.gz"}
+	stderr := &bytes.Buffer{}
+
+	err := patt.RunCLI(context.Background(), args, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("expected nothing on stderr, got %q", stderr.String())
+	}
+	report, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "coverage: 2003 lines, 2003 matched (100.0%)\n" +
+		"2003\t100.0%\t[<date>] [<level>] <message>\n" +
+		"0\t0.0%\t(unmatched)\n"
+	if string(report) != want {
+		t.Errorf("expected coverage\n%s\ngot\n%s", want, report)
+	}
+}
+
+func TestRunCLI_Stats(t *testing.T) {
+	args := []string{"patt", "--stats", "[<date>] [error] <message>", "[<date>] [notice] <message>", "<message>", "--", "testdata/Apache_3.log.gz"}
+	stderr := &bytes.Buffer{}
+
+	err := patt.RunCLI(context.Background(), args, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	want := "stats: 3 lines, 1.67 patterns tried per line\n" +
+		"1\t33.3%\t[<date>] [error] <message>\n" +
+		"2\t66.7%\t[<date>] [notice] <message>\n"
+	if stderr.String() != want {
+		t.Errorf("expected stats\n%s\ngot\n%s", want, stderr.String())
+	}
+}
@@ -0,0 +1,85 @@
+package patt
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"os"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// zlibProbeSize is the number of bytes inflated to confirm a zlib header.
+const zlibProbeSize = 4096
+
+// decompress returns a reader decoding br if it starts with the magic bytes
+// of a supported compression format, or br itself otherwise.
+func decompress(br *bufio.Reader) (io.Reader, error) {
+	// Peek returns fewer bytes with an error on short inputs, which are
+	// simply not compressed.
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case isBzip2Header(magic):
+		return bzip2.NewReader(br), nil
+	case isZlibHeader(magic):
+		prefix, err := br.Peek(min(zlibProbeSize, br.Size()))
+		if isZlibStream(prefix, err != nil) {
+			return zlib.NewReader(br)
+		}
+	}
+	return br, nil
+}
+
+// isCompressedFile reports whether the file name starts with the magic bytes
+// of a supported compression format.
+func isCompressedFile(name string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	prefix := make([]byte, zlibProbeSize)
+	n, err := io.ReadFull(f, prefix)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	prefix = prefix[:n]
+	magic := prefix[:min(n, 4)]
+	return bytes.HasPrefix(magic, gzipMagic) || isBzip2Header(magic) ||
+		isZlibHeader(magic) && isZlibStream(prefix, n < zlibProbeSize), nil
+}
+
+// isBzip2Header checks the "BZh" signature followed by the block size digit.
+func isBzip2Header(magic []byte) bool {
+	return len(magic) == 4 && bytes.HasPrefix(magic, bzip2Magic) && magic[3] >= '1' && magic[3] <= '9'
+}
+
+// isZlibHeader checks for the deflate compression method with a 32K window,
+// no preset dictionary and a valid header checksum, as defined by RFC 1950.
+func isZlibHeader(magic []byte) bool {
+	if len(magic) < 2 {
+		return false
+	}
+	cmf, flg := magic[0], magic[1]
+	return cmf == 0x78 && flg&0x20 == 0 && (uint16(cmf)<<8|uint16(flg))%31 == 0
+}
+
+// isZlibStream confirms a zlib header by inflating the prefix of the input,
+// since plain text starting with "x^" or "x?" has a valid header too. A
+// complete input must be a whole stream with a valid checksum, a longer one
+// must inflate without error up to the end of the prefix.
+func isZlibStream(prefix []byte, complete bool) bool {
+	r, err := zlib.NewReader(bytes.NewReader(prefix))
+	if err == nil {
+		_, err = io.Copy(io.Discard, r)
+	}
+	return err == nil || !complete && err == io.ErrUnexpectedEOF
+}
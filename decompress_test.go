@@ -0,0 +1,104 @@
+package patt_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+func TestBufferedFileOpener_Decompress(t *testing.T) {
+	expected, err := os.ReadFile("testdata/Apache_2k.log")
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	expected = expected[:bytes.Index(expected, []byte("\n[Sun Dec 04 04:51:09"))+1]
+
+	var zlibContent bytes.Buffer
+	zw := zlib.NewWriter(&zlibContent)
+	zw.Write(expected)
+	zw.Close()
+	zlibFile := filepath.Join(t.TempDir(), "Apache_3.log.z")
+	if err := os.WriteFile(zlibFile, zlibContent.Bytes(), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "gzip", file: "testdata/Apache_3.log.gz"},
+		{name: "bzip2", file: "testdata/Apache_3.log.bz2"},
+		{name: "zlib", file: zlibFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readAll(t, &patt.BufferedFileOpener{}, tt.file)
+			if !bytes.Equal(got, expected) {
+				t.Errorf("content = %q, want %q", got, expected)
+			}
+		})
+	}
+}
+
+func TestBufferedFileOpener_Raw(t *testing.T) {
+	expected, err := os.ReadFile("testdata/Apache_3.log.gz")
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	got := readAll(t, &patt.BufferedFileOpener{Raw: true}, "testdata/Apache_3.log.gz")
+	if !bytes.Equal(got, expected) {
+		t.Errorf("raw content differs from the file content")
+	}
+}
+
+func TestBufferedFileOpener_ShortFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "short.log")
+	if err := os.WriteFile(file, []byte("x"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if got := readAll(t, &patt.BufferedFileOpener{}, file); string(got) != "x" {
+		t.Errorf("content = %q, want %q", got, "x")
+	}
+}
+
+func readAll(t *testing.T, opener *patt.BufferedFileOpener, name string) []byte {
+	t.Helper()
+	rc, err := opener.Open(name)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	return content
+}
+
+func TestBufferedFileOpener_ZlibLikeText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "short", content: "x^\n"},
+		{name: "line", content: "x?y=1 GET /index.html 200\n"},
+		{name: "long", content: strings.Repeat("x^ Sun Dec 04 04:51:08 2005 notice jk2_init() Found child\n", 200)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "text.log")
+			if err := os.WriteFile(file, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if got := readAll(t, &patt.BufferedFileOpener{}, file); string(got) != tt.content {
+				t.Errorf("content = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
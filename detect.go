@@ -0,0 +1,184 @@
+package patt
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"patt/pattern"
+)
+
+const defaultDetectLines = 100
+
+// Detection is how well a format matches sample lines.
+type Detection struct {
+	Format  Format
+	Matched int
+	Total   int
+	// literals is the average number of literal bytes of the patterns
+	// matching the lines, more literals meaning a more specific format.
+	literals float64
+}
+
+// Fraction returns the fraction of the lines matched by the format.
+func (d Detection) Fraction() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return float64(d.Matched) / float64(d.Total)
+}
+
+// Detect returns the formats matching some of the lines, best first: those
+// matching the most lines and then the most specific ones.
+func Detect(lines [][]byte, formats []Format) ([]Detection, error) {
+	var detections []Detection
+	for _, f := range formats {
+		matchers := make([]*pattern.Matcher, len(f.Patterns))
+		literals := make([]int, len(f.Patterns))
+		for i, p := range f.Patterns {
+			m, err := pattern.ParseLineFilter([]byte(p))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s' of %s: %w", p, f.Name, err)
+			}
+			matchers[i] = m
+			lits, err := pattern.ParseLiterals(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s' of %s: %w", p, f.Name, err)
+			}
+			for _, l := range lits {
+				literals[i] += len(l)
+			}
+		}
+		d := Detection{Format: f, Total: len(lines)}
+		totalLiterals := 0
+		for _, line := range lines {
+			for i, m := range matchers {
+				if m.Test(line) {
+					d.Matched++
+					totalLiterals += literals[i]
+					break
+				}
+			}
+		}
+		if d.Matched == 0 {
+			continue
+		}
+		d.literals = float64(totalLiterals) / float64(d.Matched)
+		detections = append(detections, d)
+	}
+	slices.SortStableFunc(detections, func(a, b Detection) int {
+		if c := cmp.Compare(b.Matched, a.Matched); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.literals, a.literals)
+	})
+	return detections, nil
+}
+
+// knownFormats returns the entries of the pattern library followed by the
+// bundled formats they do not override.
+func knownFormats(library PatternLibrary) []Format {
+	var formats []Format
+	for _, name := range slices.Sorted(maps.Keys(library)) {
+		formats = append(formats, Format{Name: name, Description: "pattern library", Patterns: library[name].Patterns})
+	}
+	for _, f := range Formats {
+		if _, ok := library[f.Name]; !ok {
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
+
+// runDetect implements `patt detect [-n lines] [file]`, which reports the
+// known formats matching the first lines of the file or of stdin.
+func runDetect(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	var numLines int
+	var noDecompress bool
+	cmd := &cobra.Command{
+		Use:  "patt detect [-n lines] [file]",
+		Args: cobra.MaximumNArgs(1),
+	}
+	cmd.Flags().IntVarP(&numLines, "lines", "n", defaultDetectLines, "number of lines read")
+	cmd.Flags().BoolVar(&noDecompress, "no-decompress", false, "do not decompress a gzip, bzip2 or zlib input file")
+	if err := cmd.ParseFlags(args); err != nil {
+		return fmt.Errorf("bad parameters: %w", err)
+	}
+	if err := cmd.Args(cmd, cmd.Flags().Args()); err != nil {
+		return fmt.Errorf("bad parameters: %w", err)
+	}
+	if numLines <= 0 {
+		return errors.New("bad parameters: the number of lines must be positive")
+	}
+
+	r := io.NopCloser(stdin)
+	if files := cmd.Flags().Args(); len(files) == 1 {
+		var err error
+		r, err = (&BufferedFileOpener{Raw: noDecompress}).Open(files[0])
+		if err != nil {
+			return fmt.Errorf("cannot open input file: %w", err)
+		}
+	}
+	defer r.Close()
+	lines, err := readLines(ctx, r, numLines)
+	if err != nil {
+		return fmt.Errorf("cannot read lines: %w", err)
+	}
+	if len(lines) == 0 {
+		return ErrNoMatch
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("cannot find pattern library: %w", err)
+	}
+	library, err := ReadPatternLibrary(LibraryPaths(wd)...)
+	if err != nil {
+		return fmt.Errorf("cannot read pattern library: %w", err)
+	}
+	detections, err := Detect(lines, knownFormats(library))
+	if err != nil {
+		return err
+	}
+	if len(detections) == 0 {
+		return ErrNoMatch
+	}
+	for _, d := range detections {
+		if _, err := fmt.Fprintf(stdout, "%s: %d/%d lines (%.0f%%)\n", d.Format.Name, d.Matched, d.Total, 100*d.Fraction()); err != nil {
+			return err
+		}
+		for _, p := range d.Format.Patterns {
+			if _, err := fmt.Fprintf(stdout, "  %s\n", p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readLines returns up to n lines of r.
+func readLines(ctx context.Context, r io.Reader, n int) ([][]byte, error) {
+	lr := newLineReader(r, 0, LongLinesTruncate, nil)
+	var lines [][]byte
+	for len(lines) < n {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		line, err := lr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, slices.Clone(line))
+	}
+	return lines, nil
+}
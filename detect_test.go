@@ -0,0 +1,61 @@
+package patt_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+func TestDetect(t *testing.T) {
+	lines := [][]byte{
+		[]byte(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`),
+		[]byte(`127.0.0.1 - - [10/Oct/2000:13:55:37 -0700] "GET / HTTP/1.0" 200 512 "-" "curl/8.5.0"`),
+		[]byte(`not an access log line`),
+	}
+
+	detections, err := patt.Detect(lines, patt.Formats)
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	var got []string
+	for _, d := range detections {
+		got = append(got, d.Format.Name)
+	}
+	// Combined log lines are also matched by the common and nginx formats, the
+	// nginx one being the most specific.
+	want := []string{"nginx-access", "apache-combined", "apache-common"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Detect() = %v, want %v", got, want)
+	}
+	if f := detections[0].Fraction(); f != 2.0/3 {
+		t.Errorf("Fraction() = %v, want %v", f, 2.0/3)
+	}
+}
+
+func TestRunCLI_Detect(t *testing.T) {
+	input, err := filepath.Abs("testdata/Apache_2k.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	stdout := &bytes.Buffer{}
+	err = patt.RunCLI(context.Background(), []string{"patt", "detect", "-n", "10", input}, strings.NewReader(""), stdout, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	if want := "apache-error: 10/10 lines (100%)\n  [<time>] [<level>] <message>\n"; stdout.String() != want {
+		t.Errorf("expected output %q, got %q", want, stdout.String())
+	}
+
+	err = patt.RunCLI(context.Background(), []string{"patt", "detect"}, strings.NewReader("nothing known\n"), &bytes.Buffer{}, &bytes.Buffer{})
+	if !errors.Is(err, patt.ErrNoMatch) {
+		t.Errorf("expected ErrNoMatch, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package patt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// DistinctLimitError is returned by Distinct.Report when the capture had more
+// distinct values than the configured limit, so the report is incomplete.
+type DistinctLimitError struct {
+	Capture string
+	Limit   int
+}
+
+func (e *DistinctLimitError) Error() string {
+	return fmt.Sprintf("capture '%s' has more than %d distinct values, output is incomplete", e.Capture, e.Limit)
+}
+
+// Distinct reports the unique values of a capture in order of first
+// appearance, keeping at most limit values in memory.
+type Distinct struct {
+	capture  string
+	limit    int
+	seen     map[string]struct{}
+	values   []string
+	overflow bool
+}
+
+func NewDistinct(capture string, limit int) (*Distinct, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid distinct values limit %d, must be positive", limit)
+	}
+	return &Distinct{
+		capture: capture,
+		limit:   limit,
+		seen:    make(map[string]struct{}),
+	}, nil
+}
+
+func (d *Distinct) Add(captures Captures) {
+	value, ok := captures.Get(d.capture)
+	if !ok {
+		return
+	}
+	if _, ok := d.seen[string(value)]; ok {
+		return
+	}
+	if len(d.values) == d.limit {
+		d.overflow = true
+		return
+	}
+	d.seen[string(value)] = struct{}{}
+	d.values = append(d.values, string(value))
+}
+
+func (d *Distinct) Report(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, v := range d.values {
+		bw.WriteString(v)
+		bw.WriteByte('\n')
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if d.overflow {
+		return &DistinctLimitError{Capture: d.capture, Limit: d.limit}
+	}
+	return nil
+}
+
+// hllPrecision is the number of hash bits used to select a register. 2^14
+// registers give a standard error of about 0.8% using 16KB of memory.
+const hllPrecision = 14
+
+// Cardinality estimates the number of distinct values of a capture with HyperLogLog.
+type Cardinality struct {
+	capture   string
+	registers [1 << hllPrecision]uint8
+}
+
+func NewCardinality(capture string) *Cardinality {
+	return &Cardinality{capture: capture}
+}
+
+func (c *Cardinality) Add(captures Captures) {
+	value, ok := captures.Get(c.capture)
+	if !ok {
+		return
+	}
+	h := hash64(value)
+	ix := h >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1))) + 1
+	c.registers[ix] = max(c.registers[ix], rank)
+}
+
+// Estimate returns the estimated number of distinct values added so far.
+func (c *Cardinality) Estimate() uint64 {
+	const m = float64(len(c.registers))
+	alpha := 0.7213 / (1 + 1.079/m)
+	var sum float64
+	zeros := 0
+	for _, r := range c.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+func (c *Cardinality) Report(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%d\n", c.Estimate())
+	return err
+}
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// hash64 hashes b with FNV-1a followed by a finalizer that spreads the bits,
+// since HyperLogLog relies on every bit being uniformly distributed.
+func hash64(b []byte) uint64 {
+	x := uint64(fnvOffset64)
+	for _, c := range b {
+		x ^= uint64(c)
+		x *= fnvPrime64
+	}
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,61 @@
+package patt_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"patt"
+)
+
+func TestDistinct(t *testing.T) {
+	d, err := patt.NewDistinct("user", 10)
+	if err != nil {
+		t.Fatalf("NewDistinct() error = %v", err)
+	}
+	addValues(d, "user", "bob", "alice", "bob", "carol", "alice")
+
+	var out bytes.Buffer
+	if err := d.Report(&out); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	expected := "bob\nalice\ncarol\n"
+	if out.String() != expected {
+		t.Errorf("Report() = %q, want %q", out.String(), expected)
+	}
+}
+
+func TestDistinct_Limit(t *testing.T) {
+	d, err := patt.NewDistinct("user", 2)
+	if err != nil {
+		t.Fatalf("NewDistinct() error = %v", err)
+	}
+	addValues(d, "user", "bob", "alice", "bob", "carol")
+
+	var out bytes.Buffer
+	err = d.Report(&out)
+	var limitErr *patt.DistinctLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Report() error = %v, want DistinctLimitError", err)
+	}
+	if out.String() != "bob\nalice\n" {
+		t.Errorf("Report() = %q, want %q", out.String(), "bob\nalice\n")
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	for _, n := range []int{0, 1, 100, 10000, 200000} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			c := patt.NewCardinality("user")
+			for i := range n {
+				// Every value is added twice, duplicates must not be counted.
+				addValues(c, "user", fmt.Sprintf("user-%d", i), fmt.Sprintf("user-%d", i))
+			}
+			got := float64(c.Estimate())
+			if diff := got - float64(n); diff > 0.03*float64(n)+1 || diff < -0.03*float64(n)-1 {
+				t.Errorf("Estimate() = %v, want %d ± 3%%", got, n)
+			}
+		})
+	}
+}
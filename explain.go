@@ -0,0 +1,126 @@
+package patt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"patt/pattern"
+)
+
+// Explanation is why a line does not match a pattern.
+type Explanation struct {
+	Pattern  string
+	Mismatch pattern.Mismatch
+}
+
+// explain returns why line does not match the patterns of r, when r can tell.
+func explain(r LinesMatcher, line []byte) []Explanation {
+	if e, ok := r.(interface{ Explain([]byte) []Explanation }); ok {
+		return e.Explain(line)
+	}
+	return nil
+}
+
+// formatExplanation formats the explanation with a caret under the position
+// of the line where matching failed.
+func formatExplanation(line []byte, e Explanation) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "'%s' does not match: %s\n", e.Pattern, e.Mismatch.Reason)
+	b.WriteString("  ")
+	b.Write(line)
+	b.WriteString("\n  ")
+	for _, r := range string(line[:min(e.Mismatch.Offset, len(line))]) {
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else if r != utf8.RuneError {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString("^\n")
+	return b.String()
+}
+
+// WithExplain writes why the first n lines that do not match any pattern do
+// not match to w. The count is shared by the clones of the processor.
+func WithExplain(n int, w io.Writer) LineProcessorOption {
+	return func(p *lineProcessor) {
+		p.explain = &explainer{w: w}
+		p.explain.remaining.Store(int64(n))
+	}
+}
+
+type explainer struct {
+	remaining atomic.Int64
+	w         io.Writer
+}
+
+// explain writes the explanations of why the line does not match, computed
+// only for the first lines. The line is located by the source name and its
+// line number, or its line number alone for an unnamed source like stdin.
+func (e *explainer) explain(name string, lineNo int, line []byte, explanations func() []Explanation) error {
+	if e.remaining.Load() <= 0 || e.remaining.Add(-1) < 0 {
+		return nil
+	}
+	location := fmt.Sprintf("line %d", lineNo)
+	if name != "" {
+		location = fmt.Sprintf("%s:%d", name, lineNo)
+	}
+	var b strings.Builder
+	for _, explanation := range explanations() {
+		fmt.Fprintf(&b, "patt: %s: %s", location, formatExplanation(line, explanation))
+	}
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+// runExplain implements `patt explain pattern [line...]`, which tells why
+// each line, or each line of stdin, does or does not match the pattern.
+func runExplain(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("bad parameters: usage: patt explain pattern [line...]")
+	}
+	filter, err := NewFilter(args[0])
+	if err != nil {
+		return fmt.Errorf("cannot parse pattern: %w", err)
+	}
+	lines := make([][]byte, 0, len(args)-1)
+	for _, line := range args[1:] {
+		lines = append(lines, []byte(line))
+	}
+	if len(lines) == 0 {
+		if lines, err = readLines(ctx, stdin, defaultLearnLines); err != nil {
+			return fmt.Errorf("cannot read lines: %w", err)
+		}
+	}
+
+	matched := true
+	for _, line := range lines {
+		if !filter.Match(line) {
+			matched = false
+			for _, e := range explain(filter, line) {
+				if _, err := io.WriteString(stdout, formatExplanation(line, e)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		captures := filter.Captures(line)
+		var b strings.Builder
+		fmt.Fprintf(&b, "'%s' matches\n  %s\n", args[0], line)
+		for i, name := range captures.Names {
+			fmt.Fprintf(&b, "  <%s> = %q\n", name, captures.Values[i])
+		}
+		if _, err := io.WriteString(stdout, b.String()); err != nil {
+			return err
+		}
+	}
+	if !matched {
+		return ErrNoMatch
+	}
+	return nil
+}
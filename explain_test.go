@@ -0,0 +1,79 @@
+package patt_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+func TestRunCLI_Explain(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	err := patt.RunCLI(context.Background(), []string{"patt", "explain", "[<date>] [error] <message>",
+		"[Sun Dec 04 04:47:44 2005] [notice] ok",
+		"[Sun Dec 04 04:47:44 2005] [error] failed",
+		"[Sun Dec 04 04:47:44 2005] [error] ",
+	}, strings.NewReader(""), stdout, &bytes.Buffer{})
+	if !errors.Is(err, patt.ErrNoMatch) {
+		t.Errorf("expected ErrNoMatch, got %v", err)
+	}
+	want := `'[<date>] [error] <message>' does not match: literal "] [error] " not found after <date>
+  [Sun Dec 04 04:47:44 2005] [notice] ok
+   ^
+'[<date>] [error] <message>' matches
+  [Sun Dec 04 04:47:44 2005] [error] failed
+  <date> = "Sun Dec 04 04:47:44 2005"
+  <message> = "failed"
+'[<date>] [error] <message>' does not match: capture <message> is empty
+  [Sun Dec 04 04:47:44 2005] [error] 
+                                     ^
+`
+	if stdout.String() != want {
+		t.Errorf("expected output\n%s\ngot\n%s", want, stdout.String())
+	}
+}
+
+func TestRunCLI_ExplainFlag(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	err := patt.RunCLI(context.Background(), []string{"patt", "--explain", "1", "[<date>] [error] <message>", "[<date>] [crit] <message>", "<message>", "--", "testdata/Apache_3.log.gz"}, strings.NewReader(""), stdout, stderr)
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	if want := "mod_jk child workerEnv in error state 6\n"; stdout.String() != want {
+		t.Errorf("expected output %q, got %q", want, stdout.String())
+	}
+	wantStderr := `patt: testdata/Apache_3.log.gz:1: '[<date>] [error] <message>' does not match: literal "] [error] " not found after <date>
+  [Sun Dec 04 04:47:44 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties
+   ^
+patt: testdata/Apache_3.log.gz:1: '[<date>] [crit] <message>' does not match: literal "] [crit] " not found after <date>
+  [Sun Dec 04 04:47:44 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties
+   ^
+`
+	if stderr.String() != wantStderr {
+		t.Errorf("expected stderr\n%s\ngot\n%s", wantStderr, stderr.String())
+	}
+}
+
+func TestRunCLI_ExplainSplit(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	writeFile(t, name, "ok 1\nok 2\nok 3\nbad 4\nok 5\nbad 6\n")
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	// Chunks of two lines, processed in order by a single job.
+	err := patt.RunCLI(context.Background(), []string{"patt", "--split", "--chunk-size", "6", "-j", "1", "--explain", "2", "ok <n>", "--", name}, strings.NewReader(""), stdout, stderr)
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	if want := "ok 1\nok 2\nok 3\nok 5\n"; stdout.String() != want {
+		t.Errorf("expected output %q, got %q", want, stdout.String())
+	}
+	wantStderr := "patt: " + name + ":4: 'ok <n>' does not match: literal \"ok \" not found in the line\n  bad 4\n  ^\n" +
+		"patt: " + name + ":6: 'ok <n>' does not match: literal \"ok \" not found in the line\n  bad 6\n  ^\n"
+	if stderr.String() != wantStderr {
+		t.Errorf("expected stderr\n%s\ngot\n%s", wantStderr, stderr.String())
+	}
+}
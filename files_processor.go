@@ -1,9 +1,13 @@
 package patt
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"iter"
+	"strings"
+	"sync"
 )
 
 // FileOpener defines the interface for opening files.
@@ -18,31 +22,363 @@ type FilesProcessor struct {
 	writer     io.Writer
 	fileOpener FileOpener
 	numWorkers int
+	unordered  bool
+	failFast   bool
+	errWriter  io.Writer
 }
 
-func NewFilesProcessor(files iter.Seq[string], processor LineProcessor, writer io.Writer, fileOpener FileOpener, numWorkers int) *FilesProcessor {
-	return &FilesProcessor{
+// FilesProcessorOption configures optional behaviour of a FilesProcessor.
+type FilesProcessorOption func(*FilesProcessor)
+
+// WithUnorderedOutput writes the output of each file as soon as it is
+// available instead of in input order. The output of a file is still never
+// mixed with another in the middle of a line.
+func WithUnorderedOutput() FilesProcessorOption {
+	return func(fp *FilesProcessor) {
+		fp.unordered = true
+	}
+}
+
+// WithFailFast stops processing at the first file that cannot be processed.
+// By default the remaining files are processed, and all the errors are returned.
+func WithFailFast() FilesProcessorOption {
+	return func(fp *FilesProcessor) {
+		fp.failFast = true
+	}
+}
+
+// WithErrorWriter writes the error of each file that cannot be processed to w
+// as soon as it happens.
+func WithErrorWriter(w io.Writer) FilesProcessorOption {
+	return func(fp *FilesProcessor) {
+		fp.errWriter = w
+	}
+}
+
+// FileError is the error of a file that could not be processed.
+type FileError struct {
+	Name string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// FilesError is returned by FilesProcessor.Process when some files could not
+// be processed. The other files were processed.
+type FilesError struct {
+	Errs []*FileError
+}
+
+func (e *FilesError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *FilesError) Unwrap() []error {
+	errs := make([]error, len(e.Errs))
+	for i, err := range e.Errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+// NewFilesProcessor creates a processor that processes up to numWorkers files
+// concurrently. Each worker uses its own clone of the processor when it
+// implements Clone() LineProcessor.
+func NewFilesProcessor(files iter.Seq[string], processor LineProcessor, writer io.Writer, fileOpener FileOpener, numWorkers int, opts ...FilesProcessorOption) *FilesProcessor {
+	fp := &FilesProcessor{
 		files:      files,
 		processor:  processor,
 		writer:     writer,
 		fileOpener: fileOpener,
-		numWorkers: numWorkers,
+		numWorkers: max(numWorkers, 1),
 	}
+	for _, opt := range opts {
+		opt(fp)
+	}
+	return fp
 }
 
-func (fp *FilesProcessor) Process(ctx context.Context) (result bool, err error) {
-	for file := range fp.files {
-		rc, err := fp.fileOpener.Open(file)
-		if err != nil {
-			return false, err
+// Process processes the files and writes their output. Each file is closed as
+// soon as it has been processed. When some files cannot be processed, the
+// result of the others is returned with a *FilesError, unless failing fast.
+func (fp *FilesProcessor) Process(ctx context.Context) (bool, error) {
+	sources := func(yield func(source) bool) {
+		for name := range fp.files {
+			open := func() (io.ReadCloser, error) {
+				return fp.fileOpener.Open(name)
+			}
+			if !yield(source{name: name, open: open}) {
+				return
+			}
+		}
+	}
+	var failed []*FileError
+	onError := func(name string, err error) error {
+		fileErr := &FileError{Name: name, Err: err}
+		if fp.failFast {
+			return fileErr
 		}
-		defer rc.Close()
+		if fp.errWriter != nil {
+			fmt.Fprintf(fp.errWriter, "patt: %s\n", fileErr)
+		}
+		failed = append(failed, fileErr)
+		return nil
+	}
+	matched, err := processConcurrently(ctx, sources, fp.processor, fp.writer, fp.numWorkers, fp.unordered, onError)
+	if err != nil {
+		return false, err
+	}
+	if len(failed) > 0 {
+		return matched, &FilesError{Errs: failed}
+	}
+	return matched, nil
+}
 
-		matched, err := fp.processor.Process(ctx, rc, fp.writer)
-		if err != nil {
-			break
+// source is an input to process, like a file or a part of a file.
+type source struct {
+	name string
+	// firstLine returns the number of the first line of the source in its
+	// file, or is nil for 1.
+	firstLine func() int
+	open      func() (io.ReadCloser, error)
+}
+
+// task is a source being processed, its output is written to out.
+type task struct {
+	source
+	out     *orderedWriter
+	matched bool
+	err     error
+	done    chan struct{}
+}
+
+// processConcurrently processes up to numWorkers sources concurrently and
+// writes their output to w. Unless the output is unordered, the output of
+// each source is written after the output of the previous ones: the first
+// pending source streams its output, the others buffer it until their turn.
+// At most numWorkers sources are buffered at any time, each up to
+// orderedBufferLimit bytes after which its worker waits for its turn.
+//
+// When a source fails, onError is called with its error. Processing continues
+// unless onError is nil or returns an error, which is then returned.
+func processConcurrently(ctx context.Context, sources iter.Seq[source], processor LineProcessor, w io.Writer, numWorkers int, unordered bool, onError func(name string, err error) error) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var writeMu sync.Mutex
+	tasks := make(chan *task)
+	pending := make(chan *task, numWorkers)
+
+	go func() {
+		defer close(tasks)
+		defer close(pending)
+		for src := range sources {
+			t := &task{source: src, done: make(chan struct{})}
+			if unordered {
+				t.out = newLineAtomicWriter(w, &writeMu)
+			} else {
+				t.out = newBufferedWriter(ctx)
+			}
+			select {
+			case pending <- t:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case tasks <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var workers sync.WaitGroup
+	for range numWorkers {
+		processor := processor
+		if numWorkers > 1 {
+			processor = cloneProcessor(processor)
 		}
-		result = result || matched
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for t := range tasks {
+				t.matched, t.err = processSource(ctx, processor, t)
+				close(t.done)
+			}
+		}()
+	}
+	defer func() {
+		cancel()
+		workers.Wait()
+	}()
+
+	var result bool
+	for t := range pending {
+		if !unordered {
+			if err := t.out.stream(w); err != nil {
+				return false, err
+			}
+		}
+		select {
+		case <-t.done:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		if t.err != nil {
+			if ctx.Err() != nil || onError == nil {
+				return false, t.err
+			}
+			if err := onError(t.name, t.err); err != nil {
+				return false, err
+			}
+			continue
+		}
+		result = result || t.matched
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
 	}
 	return result, nil
 }
+
+func processSource(ctx context.Context, processor LineProcessor, t *task) (bool, error) {
+	rc, err := t.open()
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+
+	matched, err := processFrom(ctx, processor, t.name, t.firstLine, rc, t.out)
+	if err != nil {
+		return false, err
+	}
+	return matched, t.out.flush()
+}
+
+// processFrom processes r, whose first line is the line firstLine() of the
+// file name, when the processor can number the lines from there. A nil
+// firstLine stands for the first line of the file.
+func processFrom(ctx context.Context, p LineProcessor, name string, firstLine func() int, r io.Reader, w io.Writer) (bool, error) {
+	if pf, ok := p.(interface {
+		processFrom(ctx context.Context, name string, firstLine func() int, r io.Reader, w io.Writer) (bool, error)
+	}); ok {
+		return pf.processFrom(ctx, name, firstLine, r, w)
+	}
+	return p.Process(ctx, r, w)
+}
+
+// cloneProcessor returns a copy of p that can be used concurrently with p.
+func cloneProcessor(p LineProcessor) LineProcessor {
+	if c, ok := p.(interface{ Clone() LineProcessor }); ok {
+		return c.Clone()
+	}
+	return p
+}
+
+// orderedWriter buffers the output of a file until stream is called, after
+// which it writes directly to the output. Once orderedBufferLimit bytes are
+// buffered, Write blocks until stream is called or the context is done. With
+// a shared mutex, it instead writes complete lines as soon as enough of them
+// are buffered.
+type orderedWriter struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	direct io.Writer
+
+	// ctx and ready are used to wait for stream when the buffer is full,
+	// stop unregisters the wake up on cancellation.
+	ctx   context.Context
+	ready *sync.Cond
+	stop  func() bool
+
+	shared *sync.Mutex
+	out    io.Writer
+}
+
+// lineAtomicThreshold is the amount of buffered output after which a line
+// atomic writer writes its complete lines.
+const lineAtomicThreshold = 64 * 1024
+
+// orderedBufferLimit is the amount of output buffered for a file waiting for
+// the files before it to be written. This bounds the memory used by ordered
+// output to about numWorkers times this limit.
+const orderedBufferLimit = 4 << 20
+
+func newBufferedWriter(ctx context.Context) *orderedWriter {
+	w := &orderedWriter{ctx: ctx}
+	w.ready = sync.NewCond(&w.mu)
+	w.stop = context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.ready.Broadcast()
+	})
+	return w
+}
+
+func newLineAtomicWriter(out io.Writer, shared *sync.Mutex) *orderedWriter {
+	return &orderedWriter{out: out, shared: shared}
+}
+
+func (w *orderedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.ready != nil && w.direct == nil && w.buf.Len() >= orderedBufferLimit {
+		if err := w.ctx.Err(); err != nil {
+			return 0, err
+		}
+		w.ready.Wait()
+	}
+	if w.direct != nil {
+		return w.direct.Write(p)
+	}
+	w.buf.Write(p)
+	if w.shared != nil && w.buf.Len() >= lineAtomicThreshold {
+		if i := bytes.LastIndexByte(w.buf.Bytes(), '\n'); i >= 0 {
+			if err := w.writeShared(w.buf.Next(i + 1)); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(p), nil
+}
+
+func (w *orderedWriter) writeShared(p []byte) error {
+	w.shared.Lock()
+	defer w.shared.Unlock()
+	_, err := w.out.Write(p)
+	return err
+}
+
+// stream writes the buffered output to out, and any further output directly.
+func (w *orderedWriter) stream(out io.Writer) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.buf.WriteTo(out); err != nil {
+		return err
+	}
+	w.direct = out
+	if w.ready != nil {
+		w.stop()
+		w.ready.Broadcast()
+	}
+	return nil
+}
+
+// flush writes the remaining output of a line atomic writer.
+func (w *orderedWriter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.shared == nil || w.buf.Len() == 0 {
+		return nil
+	}
+	return w.writeShared(w.buf.Next(w.buf.Len()))
+}
@@ -3,12 +3,15 @@ package patt
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // mockLineProcessor is a mock implementation of the LineProcessor interface.
@@ -220,3 +223,358 @@ func TestFilesProcessor_Process_FileNotFound(t *testing.T) {
 		t.Errorf("expected buffer to be empty, got %q", buf.String())
 	}
 }
+
+// slowFirstFilesProcessFunc copies the input to the output, the lower the file
+// number the longer it takes, so that files finish in reverse order.
+func slowFirstFilesProcessFunc(n int) func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+	return func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return false, err
+		}
+		var i int
+		fmt.Sscanf(string(content), "hello world %d", &i)
+		time.Sleep(time.Duration(n-i) * 10 * time.Millisecond)
+		_, err = w.Write(content)
+		return true, err
+	}
+}
+
+func TestFilesProcessor_Process_Concurrent_Order(t *testing.T) {
+	const n = 6
+	fileNames, fileContents := makeFiles(n)
+
+	var running, maxRunning atomic.Int32
+	slow := slowFirstFilesProcessFunc(n)
+	processFunc := func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+		cur := running.Add(1)
+		defer running.Add(-1)
+		for {
+			prev := maxRunning.Load()
+			if cur <= prev || maxRunning.CompareAndSwap(prev, cur) {
+				break
+			}
+		}
+		return slow(ctx, r, w)
+	}
+
+	var buf bytes.Buffer
+	fp := NewFilesProcessor(
+		slices.Values(fileNames),
+		makeMockLineProcessor(processFunc),
+		&buf,
+		memoryFilesOpener(fileContents),
+		numWorkers,
+	)
+
+	matched, err := fp.Process(t.Context())
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if !matched {
+		t.Errorf("Process() matched = false, want true")
+	}
+	var expected strings.Builder
+	for _, f := range fileNames {
+		expected.WriteString(fileContents[f])
+	}
+	if buf.String() != expected.String() {
+		t.Errorf("output = %q, expected %q", buf.String(), expected.String())
+	}
+	if maxRunning.Load() < 2 {
+		t.Errorf("files were processed sequentially")
+	}
+}
+
+func TestFilesProcessor_Process_Unordered(t *testing.T) {
+	const n = 6
+	fileNames, fileContents := makeFiles(n)
+
+	var buf bytes.Buffer
+	fp := NewFilesProcessor(
+		slices.Values(fileNames),
+		makeMockLineProcessor(slowFirstFilesProcessFunc(n)),
+		&buf,
+		memoryFilesOpener(fileContents),
+		n,
+		WithUnorderedOutput(),
+	)
+
+	matched, err := fp.Process(t.Context())
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if !matched {
+		t.Errorf("Process() matched = false, want true")
+	}
+	var expected strings.Builder
+	for i := range fileNames {
+		expected.WriteString(fileContents[fileNames[n-1-i]])
+	}
+	if buf.String() != expected.String() {
+		t.Errorf("output = %q, expected %q", buf.String(), expected.String())
+	}
+}
+
+func TestFilesProcessor_Process_LargeUnorderedOutput(t *testing.T) {
+	fileNames, _ := makeFiles(4)
+	line := strings.Repeat("x", 99) + "\n"
+	fileContents := make(map[string]string)
+	for _, name := range fileNames {
+		fileContents[name] = strings.Repeat(line, 2000)
+	}
+	processFunc := func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+		// Small writes that do not end on line boundaries.
+		_, err := io.CopyBuffer(w, struct{ io.Reader }{r}, make([]byte, 33))
+		return true, err
+	}
+
+	var buf bytes.Buffer
+	fp := NewFilesProcessor(
+		slices.Values(fileNames),
+		makeMockLineProcessor(processFunc),
+		&buf,
+		memoryFilesOpener(fileContents),
+		numWorkers,
+		WithUnorderedOutput(),
+	)
+
+	if _, err := fp.Process(t.Context()); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if buf.Len() != 4*2000*len(line) {
+		t.Fatalf("output length = %d, want %d", buf.Len(), 4*2000*len(line))
+	}
+	for l := range strings.Lines(buf.String()) {
+		if l != line {
+			t.Fatalf("output has mixed line %q", l)
+		}
+	}
+}
+
+func TestFilesProcessor_Process_Canceled(t *testing.T) {
+	fileNames, fileContents := makeFiles(10)
+	ctx, cancel := context.WithCancel(t.Context())
+	processFunc := func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+		cancel()
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+
+	fp := NewFilesProcessor(
+		slices.Values(fileNames),
+		makeMockLineProcessor(processFunc),
+		io.Discard,
+		memoryFilesOpener(fileContents),
+		numWorkers,
+	)
+
+	if _, err := fp.Process(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Process() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestFilesProcessor_Process_ContinueOnError(t *testing.T) {
+	fileNames, fileContents := makeFiles(2)
+	names := []string{fileNames[0], "nonexistent.txt", fileNames[1]}
+	processFunc := func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+		_, err := io.Copy(w, r)
+		return true, err
+	}
+
+	var buf, errBuf bytes.Buffer
+	fp := NewFilesProcessor(
+		slices.Values(names),
+		makeMockLineProcessor(processFunc),
+		&buf,
+		memoryFilesOpener(fileContents),
+		numWorkers,
+		WithErrorWriter(&errBuf),
+	)
+
+	matched, err := fp.Process(t.Context())
+	var filesErr *FilesError
+	if !errors.As(err, &filesErr) || len(filesErr.Errs) != 1 {
+		t.Fatalf("Process() error = %v, want FilesError with 1 error", err)
+	}
+	if filesErr.Errs[0].Name != "nonexistent.txt" || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Process() error = %v, want not exist error for nonexistent.txt", err)
+	}
+	if !matched {
+		t.Errorf("Process() matched = false, want true")
+	}
+	expected := fileContents[fileNames[0]] + fileContents[fileNames[1]]
+	if buf.String() != expected {
+		t.Errorf("output = %q, want %q", buf.String(), expected)
+	}
+	if errBuf.String() != "patt: nonexistent.txt: file does not exist\n" {
+		t.Errorf("errors = %q", errBuf.String())
+	}
+}
+
+func TestFilesProcessor_Process_FailFast(t *testing.T) {
+	fileNames, fileContents := makeFiles(2)
+	names := []string{"nonexistent.txt", fileNames[0], fileNames[1]}
+
+	var buf bytes.Buffer
+	fp := NewFilesProcessor(
+		slices.Values(names),
+		makeMockLineProcessor(func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+			_, err := io.Copy(w, r)
+			return true, err
+		}),
+		&buf,
+		memoryFilesOpener(fileContents),
+		1,
+		WithFailFast(),
+	)
+
+	matched, err := fp.Process(t.Context())
+	var fileErr *FileError
+	if !errors.As(err, &fileErr) || fileErr.Name != "nonexistent.txt" {
+		t.Fatalf("Process() error = %v, want FileError for nonexistent.txt", err)
+	}
+	if matched {
+		t.Errorf("Process() matched = true, want false")
+	}
+}
+
+func TestFilesProcessor_Process_ProcessorError(t *testing.T) {
+	fileNames, fileContents := makeFiles(1)
+	processErr := errors.New("read failure")
+
+	fp := NewFilesProcessor(
+		slices.Values(fileNames),
+		makeMockLineProcessor(func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+			return true, processErr
+		}),
+		io.Discard,
+		memoryFilesOpener(fileContents),
+		numWorkers,
+	)
+
+	if _, err := fp.Process(t.Context()); !errors.Is(err, processErr) {
+		t.Errorf("Process() error = %v, want %v", err, processErr)
+	}
+}
+
+// largeOutputProcessFunc writes 2*orderedBufferLimit bytes for every file
+// but the first, which waits for firstDone to be closed and then returns
+// firstErr. written counts the bytes of the other files written before
+// firstDone is closed.
+func largeOutputProcessFunc(firstDone chan struct{}, firstErr error, written *atomic.Int64) func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+	chunk := bytes.Repeat([]byte(strings.Repeat("x", 1023)+"\n"), 64)
+	return func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return false, err
+		}
+		if string(content) == "hello world 0\n" {
+			<-firstDone
+			_, err := w.Write(content)
+			return true, errors.Join(err, firstErr)
+		}
+		for range 2 * orderedBufferLimit / len(chunk) {
+			if _, err := w.Write(chunk); err != nil {
+				return false, err
+			}
+			select {
+			case <-firstDone:
+			default:
+				written.Add(int64(len(chunk)))
+			}
+		}
+		return true, nil
+	}
+}
+
+func TestFilesProcessor_Process_BoundedBuffers(t *testing.T) {
+	fileNames, fileContents := makeFiles(numWorkers)
+	firstDone := make(chan struct{})
+	var written atomic.Int64
+
+	var buf bytes.Buffer
+	fp := NewFilesProcessor(
+		slices.Values(fileNames),
+		makeMockLineProcessor(largeOutputProcessFunc(firstDone, nil, &written)),
+		&buf,
+		memoryFilesOpener(fileContents),
+		numWorkers,
+	)
+
+	time.AfterFunc(100*time.Millisecond, func() { close(firstDone) })
+	if _, err := fp.Process(t.Context()); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	// Each waiting worker writes up to the limit, plus the chunk filling it.
+	if limit := int64(numWorkers-1) * (orderedBufferLimit + 64*1024); written.Load() > limit {
+		t.Errorf("buffered %d bytes while the first file was processed, want at most %d", written.Load(), limit)
+	}
+	if want := len(fileContents[fileNames[0]]) + (numWorkers-1)*2*orderedBufferLimit; buf.Len() != want {
+		t.Errorf("output length = %d, want %d", buf.Len(), want)
+	}
+	if !strings.HasPrefix(buf.String(), fileContents[fileNames[0]]) {
+		t.Errorf("output does not start with the first file")
+	}
+}
+
+func TestFilesProcessor_Process_BoundedBuffersFailFast(t *testing.T) {
+	fileNames, fileContents := makeFiles(numWorkers)
+	firstDone := make(chan struct{})
+	processErr := errors.New("read failure")
+	var written atomic.Int64
+
+	fp := NewFilesProcessor(
+		slices.Values(fileNames),
+		makeMockLineProcessor(largeOutputProcessFunc(firstDone, processErr, &written)),
+		io.Discard,
+		memoryFilesOpener(fileContents),
+		numWorkers,
+		WithFailFast(),
+	)
+
+	// The workers waiting for their turn stop when the first file fails.
+	time.AfterFunc(50*time.Millisecond, func() { close(firstDone) })
+	if _, err := fp.Process(t.Context()); !errors.Is(err, processErr) {
+		t.Errorf("Process() error = %v, want %v", err, processErr)
+	}
+}
+
+// closeRecorder records whether it has been closed.
+type closeRecorder struct {
+	io.Reader
+	closed *atomic.Int32
+}
+
+func (c closeRecorder) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func TestFilesProcessor_Process_ClosesEachFile(t *testing.T) {
+	fileNames, fileContents := makeFiles(3)
+	var closed atomic.Int32
+	opener := &mockFileOpener{files: make(map[string]io.ReadCloser)}
+	for name, content := range fileContents {
+		opener.files[name] = closeRecorder{Reader: strings.NewReader(content), closed: &closed}
+	}
+
+	var closedBefore []int32
+	fp := NewFilesProcessor(
+		slices.Values(fileNames),
+		makeMockLineProcessor(func(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
+			closedBefore = append(closedBefore, closed.Load())
+			return true, nil
+		}),
+		io.Discard,
+		opener,
+		1,
+	)
+
+	if _, err := fp.Process(t.Context()); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if diff := slices.Compare(closedBefore, []int32{0, 1, 2}); diff != 0 || closed.Load() != 3 {
+		t.Errorf("files closed before processing = %v, total %d", closedBefore, closed.Load())
+	}
+}
@@ -0,0 +1,121 @@
+package patt
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+const defaultPollInterval = 250 * time.Millisecond
+
+// followReader reads a file like `tail -F`: once the end of the file is
+// reached it waits for more data instead of returning io.EOF. When the file
+// is truncated it starts reading from the beginning again, and when it is
+// replaced (as done by log rotation) the new file is opened once the old one
+// has been read until the end.
+//
+// Read returns io.EOF only when the context is done.
+type followReader struct {
+	ctx    context.Context
+	name   string
+	poll   time.Duration
+	f      *os.File
+	info   os.FileInfo
+	offset int64
+}
+
+// NewFollowReader opens the file name for following. Like tail -F, only the
+// data written after the file is opened is read, unless fromStart is true to
+// read the file from the beginning. The file is then polled every poll
+// interval for new data.
+func NewFollowReader(ctx context.Context, name string, poll time.Duration, fromStart bool) (io.ReadCloser, error) {
+	if poll <= 0 {
+		poll = defaultPollInterval
+	}
+	fr := &followReader{ctx: ctx, name: name, poll: poll}
+	if err := fr.open(); err != nil {
+		return nil, err
+	}
+	if !fromStart {
+		offset, err := fr.f.Seek(0, io.SeekEnd)
+		if err != nil {
+			fr.f.Close()
+			return nil, err
+		}
+		fr.offset = offset
+	}
+	return fr, nil
+}
+
+func (fr *followReader) open() error {
+	f, err := os.Open(fr.name)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if fr.f != nil {
+		fr.f.Close()
+	}
+	fr.f, fr.info, fr.offset = f, info, 0
+	return nil
+}
+
+func (fr *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := fr.f.Read(p)
+		fr.offset += int64(n)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		changed, err := fr.checkFile()
+		if err != nil {
+			return 0, err
+		}
+		if changed {
+			continue
+		}
+		select {
+		case <-fr.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(fr.poll):
+		}
+	}
+}
+
+// checkFile detects truncation and rotation of the followed file once the
+// end of the open file has been reached. It reports whether reading should
+// start over on a new or truncated file.
+func (fr *followReader) checkFile() (bool, error) {
+	info, err := os.Stat(fr.name)
+	if err != nil {
+		// The file was moved away and not recreated yet.
+		return false, nil
+	}
+	if !os.SameFile(info, fr.info) {
+		// Drain what was written to the old file before it was replaced.
+		if current, err := fr.f.Stat(); err == nil && current.Size() > fr.offset {
+			return true, nil
+		}
+		return true, fr.open()
+	}
+	if info.Size() < fr.offset {
+		if _, err := fr.f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		fr.offset = 0
+		return true, nil
+	}
+	return false, nil
+}
+
+func (fr *followReader) Close() error {
+	return fr.f.Close()
+}
@@ -0,0 +1,151 @@
+package patt_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"patt"
+)
+
+const testPollInterval = 5 * time.Millisecond
+
+func TestFollowReader(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	writeFile(t, name, "one\n")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	rc, err := patt.NewFollowReader(ctx, name, testPollInterval, true)
+	if err != nil {
+		t.Fatalf("NewFollowReader() error = %v", err)
+	}
+	defer rc.Close()
+
+	lines := make(chan string)
+	done := make(chan error)
+	go func() {
+		scanner := bufio.NewScanner(rc)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		done <- scanner.Err()
+	}()
+	expectLine := func(expected string) {
+		t.Helper()
+		select {
+		case line := <-lines:
+			if line != expected {
+				t.Fatalf("read %q, want %q", line, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %q", expected)
+		}
+	}
+
+	expectLine("one")
+
+	appendFile(t, name, "two\n")
+	expectLine("two")
+
+	// Truncation starts over from the beginning of the file.
+	writeFile(t, name, "3\n")
+	expectLine("3")
+
+	// Rotation reads the rest of the old file, then the new one.
+	appendFile(t, name, "four\n")
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatalf("failed to rotate file: %v", err)
+	}
+	writeFile(t, name, "five\n")
+	expectLine("four")
+	expectLine("five")
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader did not stop after cancellation")
+	}
+}
+
+func TestRunCLI_Follow(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	writeFile(t, name, "something match\nother line\n")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 300*time.Millisecond)
+	defer cancel()
+	stdout := &bytes.Buffer{}
+	err := patt.RunCLI(ctx, []string{"patt", "-f", "--from-start", "something <placeholder>", "found <placeholder>!", "--", name}, nil, stdout, io.Discard)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if stdout.String() != "found match!\n" {
+		t.Errorf("expected stdout %q, got %q", "found match!\n", stdout.String())
+	}
+}
+
+func TestFollowReader_FromEnd(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	writeFile(t, name, "old\n")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	rc, err := patt.NewFollowReader(ctx, name, testPollInterval, false)
+	if err != nil {
+		t.Fatalf("NewFollowReader() error = %v", err)
+	}
+	defer rc.Close()
+
+	appendFile(t, name, "new\n")
+	line, err := bufio.NewReader(rc).ReadString('\n')
+	if err != nil {
+		t.Fatalf("ReadString() error = %v", err)
+	}
+	if line != "new\n" {
+		t.Errorf("read %q, want %q", line, "new\n")
+	}
+}
+
+func TestRunCLI_FollowFromEnd(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	writeFile(t, name, "something match\nother line\n")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	stdout := &bytes.Buffer{}
+	err := patt.RunCLI(ctx, []string{"patt", "-f", "something <placeholder>", "--", name}, nil, stdout, io.Discard)
+	if patt.ExitCode(err) != patt.ExitNoMatch {
+		t.Errorf("expected no match, got error %v", err)
+	}
+	if stdout.String() != "" {
+		t.Errorf("expected empty stdout, got %q", stdout.String())
+	}
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+func appendFile(t *testing.T, name, content string) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
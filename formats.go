@@ -0,0 +1,138 @@
+package patt
+
+import (
+	"fmt"
+	"io"
+)
+
+// Format is a well known log format, selectable by name with --use.
+type Format struct {
+	Name        string
+	Description string
+	// Patterns match the lines of the format, the most specific first.
+	Patterns []string
+	// Example is a line of the format.
+	Example string
+}
+
+// Formats is the catalog of bundled log formats. Pattern library entries of
+// the same name take precedence.
+var Formats = []Format{
+	{
+		Name:        "apache-common",
+		Description: "Apache Common Log Format access log",
+		Patterns:    []string{`<ip> <ident> <user> [<time>] "<method> <path> <protocol>" <status> <size>`},
+		Example:     `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+	},
+	{
+		Name:        "apache-combined",
+		Description: "Apache Combined Log Format access log",
+		Patterns:    []string{`<ip> <ident> <user> [<time>] "<method> <path> <protocol>" <status> <size> "<referer>" "<user_agent>"`},
+		Example:     `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`,
+	},
+	{
+		Name:        "apache-error",
+		Description: "Apache error log",
+		Patterns:    []string{`[<time>] [<level>] <message>`},
+		Example:     `[Sun Dec 04 04:47:44 2005] [error] mod_jk child workerEnv in error state 6`,
+	},
+	{
+		Name:        "nginx-access",
+		Description: "nginx access log, default combined format",
+		Patterns:    []string{`<remote_addr> - <remote_user> [<time_local>] "<method> <path> <protocol>" <status> <body_bytes_sent> "<http_referer>" "<http_user_agent>"`},
+		Example:     `192.168.1.10 - - [12/Mar/2024:10:15:32 +0000] "GET /index.html HTTP/1.1" 200 612 "-" "curl/8.5.0"`,
+	},
+	{
+		Name:        "syslog-rfc3164",
+		Description: "BSD syslog (RFC 3164), with or without priority",
+		Patterns: []string{
+			`<<pri>><month> <day> <time> <host> <program>: <message>`,
+			`<<pri>><month>  <day> <time> <host> <program>: <message>`,
+			`<month> <day> <time> <host> <program>: <message>`,
+			`<month>  <day> <time> <host> <program>: <message>`,
+		},
+		Example: `<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8`,
+	},
+	{
+		Name:        "syslog-rfc5424",
+		Description: "IETF syslog (RFC 5424)",
+		Patterns: []string{
+			`<<pri>>1 <timestamp> <host> <app> <procid> <msgid> - <message>`,
+			`<<pri>>1 <timestamp> <host> <app> <procid> <msgid> [<structured_data>] <message>`,
+		},
+		Example: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3"] An application event log entry`,
+	},
+	{
+		Name:        "journald",
+		Description: "journalctl short output",
+		Patterns: []string{
+			`<month> <day> <time> <host> <unit>[<pid>]: <message>`,
+			`<month> <day> <time> <host> <unit>: <message>`,
+		},
+		Example: `Mar 12 10:15:32 web01 systemd[1]: Started Session 42 of User root.`,
+	},
+	{
+		Name:        "go-panic",
+		Description: "Go panic and goroutine stack headers",
+		Patterns: []string{
+			`panic: <message>`,
+			`goroutine <id> [<state>]:`,
+		},
+		Example: `goroutine 1 [running]:`,
+	},
+	{
+		Name:        "python-traceback",
+		Description: "Python traceback header and frames",
+		Patterns: []string{
+			`Traceback (most recent call last):`,
+			`  File "<file>", line <line>, in <function>`,
+		},
+		Example: `  File "/app/main.py", line 12, in handler`,
+	},
+	{
+		Name:        "java-exception",
+		Description: "Java exception header, causes and frames",
+		Patterns: []string{
+			`Exception in thread "<thread>" <exception>: <message>`,
+			`Caused by: <exception>: <message>`,
+			"\tat <method>(<location>)",
+		},
+		Example: `Exception in thread "main" java.lang.IllegalStateException: not ready`,
+	},
+}
+
+// LookupFormat returns the bundled format called name.
+func LookupFormat(name string) (Format, bool) {
+	for _, f := range Formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// runFormats lists the bundled formats, or only those named in args.
+func runFormats(args []string, w io.Writer) error {
+	formats := Formats
+	if len(args) > 0 {
+		formats = nil
+		for _, name := range args {
+			f, ok := LookupFormat(name)
+			if !ok {
+				return fmt.Errorf("unknown format '%s'", name)
+			}
+			formats = append(formats, f)
+		}
+	}
+	for _, f := range formats {
+		if _, err := fmt.Fprintf(w, "%s: %s\n", f.Name, f.Description); err != nil {
+			return err
+		}
+		for _, p := range f.Patterns {
+			if _, err := fmt.Fprintf(w, "  %s\n", p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package patt_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+// formatSamples are lines that must be matched by the patterns of each format.
+var formatSamples = map[string][]string{
+	"apache-common": {
+		`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+		`10.0.0.2 - - [04/Dec/2005:04:47:44 +0000] "POST /login HTTP/1.1" 302 -`,
+	},
+	"apache-combined": {
+		`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`,
+	},
+	"apache-error": {
+		`[Sun Dec 04 04:47:44 2005] [notice] workerEnv.init() ok /etc/httpd/conf/workers2.properties`,
+		`[Sun Dec 04 04:47:44 2005] [error] mod_jk child workerEnv in error state 6`,
+	},
+	"nginx-access": {
+		`192.168.1.10 - - [12/Mar/2024:10:15:32 +0000] "GET /index.html HTTP/1.1" 200 612 "-" "curl/8.5.0"`,
+		`2001:db8::1 - alice [12/Mar/2024:10:15:33 +0000] "POST /api/v1/items?id=3 HTTP/2.0" 201 17 "https://example.com/" "Mozilla/5.0 (X11; Linux x86_64)"`,
+	},
+	"syslog-rfc3164": {
+		`<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8`,
+		`Oct 11 22:14:15 mymachine sshd[4721]: Accepted publickey for root`,
+		`Oct  1 02:00:01 mymachine CRON[981]: (root) CMD (run-parts /etc/cron.hourly)`,
+	},
+	"syslog-rfc5424": {
+		`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3"] An application event log entry`,
+		`<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8`,
+	},
+	"journald": {
+		`Mar 12 10:15:32 web01 systemd[1]: Started Session 42 of User root.`,
+		`Mar 02 08:00:00 web01 kernel: Linux version 6.1.0`,
+	},
+	"go-panic": {
+		`panic: runtime error: index out of range [5] with length 3`,
+		`goroutine 1 [running]:`,
+	},
+	"python-traceback": {
+		`Traceback (most recent call last):`,
+		`  File "/app/main.py", line 12, in handler`,
+	},
+	"java-exception": {
+		`Exception in thread "main" java.lang.IllegalStateException: not ready`,
+		`Caused by: java.io.IOException: Connection reset`,
+		"\tat com.example.App.main(App.java:42)",
+	},
+}
+
+func TestFormats(t *testing.T) {
+	for _, f := range patt.Formats {
+		t.Run(f.Name, func(t *testing.T) {
+			samples, ok := formatSamples[f.Name]
+			if !ok {
+				t.Fatalf("no sample lines for format %s", f.Name)
+			}
+			filter, err := patt.NewMultiFilter(f.Patterns)
+			if err != nil {
+				t.Fatalf("invalid patterns: %v", err)
+			}
+			for _, line := range append(samples, f.Example) {
+				if !filter.Match([]byte(line)) {
+					t.Errorf("line %q is not matched", line)
+				}
+			}
+		})
+	}
+}
+
+func TestFormats_ApacheErrorLog(t *testing.T) {
+	f, ok := patt.LookupFormat("apache-error")
+	if !ok {
+		t.Fatal("apache-error format not found")
+	}
+	filter, err := patt.NewMultiFilter(f.Patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open("testdata/Apache_2k.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if !filter.Match(scanner.Bytes()) {
+			t.Errorf("line %q is not matched", scanner.Text())
+		}
+	}
+}
+
+func TestRunCLI_Formats(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	err := patt.RunCLI(context.Background(), []string{"patt", "formats", "apache-error"}, strings.NewReader(""), stdout, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	if want := "apache-error: Apache error log\n  [<time>] [<level>] <message>\n"; stdout.String() != want {
+		t.Errorf("expected output %q, got %q", want, stdout.String())
+	}
+
+	err = patt.RunCLI(context.Background(), []string{"patt", "formats", "unknown"}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+	if err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+
+	stdout.Reset()
+	err = patt.RunCLI(context.Background(), []string{"patt", "--use", "apache-error", "<level>", "--", "testdata/Apache_3.log.gz"}, strings.NewReader(""), stdout, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	if want := "notice\nerror\nnotice\n"; stdout.String() != want {
+		t.Errorf("expected output %q, got %q", want, stdout.String())
+	}
+}
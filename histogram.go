@@ -0,0 +1,236 @@
+package patt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayoutPresets maps preset names accepted by --time-layout to Go time layouts.
+var timeLayoutPresets = map[string]string{
+	"apache":   "Mon Jan 02 15:04:05 2006",
+	"clf":      "02/Jan/2006:15:04:05 -0700",
+	"rfc3339":  time.RFC3339Nano,
+	"datetime": time.DateTime,
+	"syslog":   time.Stamp,
+	"unix":     unixLayout,
+}
+
+// unixLayout stands for seconds since the epoch, optionally with a fraction.
+const unixLayout = "unix"
+
+// autoLayouts is the order in which presets are tried when no layout is configured.
+var autoLayouts = []string{"rfc3339", "datetime", "apache", "clf", "syslog", "unix"}
+
+// timeParser parses timestamps with a fixed layout, or tries the known presets
+// when the layout is "auto", remembering the last one that worked.
+type timeParser struct {
+	layouts []string
+	last    int
+	// auto only accepts values looking like epoch timestamps as unix times.
+	auto bool
+}
+
+func newTimeParser(layout string) *timeParser {
+	if layout == "" || layout == "auto" {
+		layouts := make([]string, len(autoLayouts))
+		for i, preset := range autoLayouts {
+			layouts[i] = timeLayoutPresets[preset]
+		}
+		return &timeParser{layouts: layouts, auto: true}
+	}
+	if preset, ok := timeLayoutPresets[layout]; ok {
+		layout = preset
+	}
+	return &timeParser{layouts: []string{layout}}
+}
+
+func (tp *timeParser) parse(value string) (time.Time, bool) {
+	for i := range tp.layouts {
+		ix := (tp.last + i) % len(tp.layouts)
+		if tp.auto && tp.layouts[ix] == unixLayout && !looksLikeEpoch(value) {
+			continue
+		}
+		if t, ok := parseTime(tp.layouts[ix], value); ok {
+			tp.last = ix
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// looksLikeEpoch reports whether value is a number of seconds since the epoch
+// between 1973 and 2286, with 9 or 10 digits and an optional fraction, so that
+// small numbers are not taken for times in 1970.
+func looksLikeEpoch(value string) bool {
+	secs, _, _ := strings.Cut(value, ".")
+	if len(secs) < 9 || len(secs) > 10 {
+		return false
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil && strings.Trim(secs, "0123456789") == ""
+}
+
+func parseTime(layout, value string) (time.Time, bool) {
+	if layout == unixLayout {
+		secs, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(0, int64(secs*float64(time.Second))).UTC(), true
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if t.Year() == 0 {
+		t = withCurrentYear(t, time.Now())
+	}
+	return t, true
+}
+
+// withCurrentYear sets the year of a time parsed without one, like syslog
+// timestamps, to the year of now, or to the previous year if the time would
+// be more than a day after now, as for December logs read in January.
+func withCurrentYear(t, now time.Time) time.Time {
+	year := now.Year()
+	if t.AddDate(year, 0, 0).After(now.Add(24 * time.Hour)) {
+		year--
+	}
+	return time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// maxHistogramBuckets is the number of buckets reported at most. The buckets
+// outside of the range with the most values are reported as out of range.
+const maxHistogramBuckets = 10000
+
+// TimeHistogram counts matching lines into fixed-width buckets of the time
+// parsed from a capture.
+type TimeHistogram struct {
+	capture    string
+	parser     *timeParser
+	bucket     time.Duration
+	sparkline  bool
+	counts     map[int64]int
+	unparsable int
+	loc        *time.Location
+}
+
+// NewTimeHistogram creates a histogram of the capture named capture. layout is
+// either a Go time layout, a preset name (apache, clf, rfc3339, datetime,
+// syslog, unix) or "auto" to detect it from the values.
+func NewTimeHistogram(capture, layout string, bucket time.Duration, sparkline bool) (*TimeHistogram, error) {
+	if bucket <= 0 {
+		return nil, fmt.Errorf("invalid bucket width %s, must be positive", bucket)
+	}
+	return &TimeHistogram{
+		capture:   capture,
+		parser:    newTimeParser(layout),
+		bucket:    bucket,
+		sparkline: sparkline,
+		counts:    make(map[int64]int),
+	}, nil
+}
+
+func (h *TimeHistogram) Add(captures Captures) {
+	value, ok := captures.Get(h.capture)
+	if !ok {
+		h.unparsable++
+		return
+	}
+	t, ok := h.parser.parse(string(value))
+	if !ok {
+		h.unparsable++
+		return
+	}
+	if t.Year() < 1678 || t.Year() > 2261 {
+		// Out of the range of the buckets, in nanoseconds since the epoch.
+		h.unparsable++
+		return
+	}
+	if h.loc == nil {
+		h.loc = t.Location()
+	}
+	h.counts[t.Truncate(h.bucket).UnixNano()]++
+}
+
+// Report writes one line per bucket between the first and the last seen
+// timestamp, or a single sparkline when configured to. At most
+// maxHistogramBuckets buckets are written, with the number of values out of
+// their range.
+func (h *TimeHistogram) Report(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	first, last, outOfRange := h.bounds()
+	if len(h.counts) > 0 {
+		if h.sparkline {
+			h.writeSparkline(bw, first, last)
+		} else {
+			h.writeTable(bw, first, last)
+		}
+	}
+	if outOfRange > 0 {
+		fmt.Fprintf(bw, "out of range\t%d\n", outOfRange)
+	}
+	if h.unparsable > 0 {
+		fmt.Fprintf(bw, "unparsable\t%d\n", h.unparsable)
+	}
+	return bw.Flush()
+}
+
+// bounds returns the first and last buckets reported, spanning at most
+// maxHistogramBuckets buckets with the most values, and the number of values
+// out of this range.
+func (h *TimeHistogram) bounds() (first, last int64, outOfRange int) {
+	buckets := slices.Sorted(maps.Keys(h.counts))
+	if len(buckets) == 0 {
+		return 0, 0, 0
+	}
+	width := int64(h.bucket) * (maxHistogramBuckets - 1)
+	bestStart, bestEnd, best := 0, 0, -1
+	total, inWindow := 0, 0
+	start := 0
+	for end, b := range buckets {
+		total += h.counts[b]
+		inWindow += h.counts[b]
+		for b-buckets[start] > width {
+			inWindow -= h.counts[buckets[start]]
+			start++
+		}
+		if inWindow > best {
+			bestStart, bestEnd, best = start, end, inWindow
+		}
+	}
+	return buckets[bestStart], buckets[bestEnd], total - best
+}
+
+func (h *TimeHistogram) writeTable(w *bufio.Writer, first, last int64) {
+	for b := first; b <= last; b += int64(h.bucket) {
+		fmt.Fprintf(w, "%s\t%d\n", time.Unix(0, b).In(h.loc).Format(time.DateTime), h.counts[b])
+	}
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+func (h *TimeHistogram) writeSparkline(w *bufio.Writer, first, last int64) {
+	peak := 0
+	for b := first; b <= last; b += int64(h.bucket) {
+		peak = max(peak, h.counts[b])
+	}
+	var line strings.Builder
+	for b := first; b <= last; b += int64(h.bucket) {
+		if h.counts[b] == 0 {
+			line.WriteByte(' ')
+			continue
+		}
+		line.WriteRune(sparks[h.counts[b]*(len(sparks)-1)/peak])
+	}
+	fmt.Fprintf(w, "%s .. %s every %s (max %d)\n%s\n",
+		time.Unix(0, first).In(h.loc).Format(time.DateTime),
+		time.Unix(0, last).In(h.loc).Format(time.DateTime),
+		h.bucket, peak, line.String())
+}
@@ -0,0 +1,116 @@
+package patt_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"patt"
+)
+
+func TestTimeHistogram(t *testing.T) {
+	tests := []struct {
+		name      string
+		layout    string
+		bucket    time.Duration
+		sparkline bool
+		values    []string
+		expected  string
+	}{
+		{
+			name:   "apache preset with empty buckets",
+			layout: "apache",
+			bucket: time.Minute,
+			values: []string{"Sun Dec 04 04:47:44 2005", "Sun Dec 04 04:47:59 2005", "Sun Dec 04 04:49:01 2005"},
+			expected: "2005-12-04 04:47:00\t2\n" +
+				"2005-12-04 04:48:00\t0\n" +
+				"2005-12-04 04:49:00\t1\n",
+		},
+		{
+			name:   "auto layout",
+			bucket: time.Hour,
+			values: []string{"2025-01-02T03:04:05Z", "2025-01-02T04:59:59Z"},
+			expected: "2025-01-02 03:00:00\t1\n" +
+				"2025-01-02 04:00:00\t1\n",
+		},
+		{
+			name:   "custom layout and unparsable values",
+			layout: "2006/01/02 15:04",
+			bucket: time.Minute,
+			values: []string{"2025/01/02 03:04", "yesterday"},
+			expected: "2025-01-02 03:04:00\t1\n" +
+				"unparsable\t1\n",
+		},
+		{
+			name:   "auto layout does not take small numbers for unix times",
+			bucket: time.Hour,
+			values: []string{"2025-01-02T03:04:05Z", "1", "1735787045.5"},
+			expected: "2025-01-02 03:00:00\t2\n" +
+				"unparsable\t1\n",
+		},
+		{
+			name:   "buckets out of range",
+			layout: "unix",
+			bucket: time.Second,
+			values: []string{"0", "2000000000", "2000000001", "2000000001"},
+			expected: "2033-05-18 03:33:20\t1\n" +
+				"2033-05-18 03:33:21\t2\n" +
+				"out of range\t1\n",
+		},
+		{
+			name:   "syslog timestamps without year",
+			layout: "syslog",
+			bucket: time.Hour,
+			values: []string{"Jan  1 00:14:15", "Jan  1 01:02:03"},
+			expected: fmt.Sprintf("%d-01-01 00:00:00\t1\n", time.Now().Year()) +
+				fmt.Sprintf("%d-01-01 01:00:00\t1\n", time.Now().Year()),
+		},
+		{
+			name:     "auto layout with syslog timestamps",
+			bucket:   time.Hour,
+			values:   []string{"Jan  1 00:14:15"},
+			expected: fmt.Sprintf("%d-01-01 00:00:00\t1\n", time.Now().Year()),
+		},
+		{
+			name:     "years out of range",
+			layout:   "2006-01-02",
+			bucket:   time.Hour,
+			values:   []string{"1066-10-14"},
+			expected: "unparsable\t1\n",
+		},
+		{
+			name:      "sparkline",
+			layout:    "unix",
+			bucket:    time.Second,
+			sparkline: true,
+			values:    []string{"0", "0", "2"},
+			expected:  "1970-01-01 00:00:00 .. 1970-01-01 00:00:02 every 1s (max 2)\n█ ▄\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := patt.NewTimeHistogram("ts", tt.layout, tt.bucket, tt.sparkline)
+			if err != nil {
+				t.Fatalf("NewTimeHistogram() error = %v", err)
+			}
+			for _, v := range tt.values {
+				h.Add(patt.Captures{Names: []string{"ts"}, Values: [][]byte{[]byte(v)}})
+			}
+			var out bytes.Buffer
+			if err := h.Report(&out); err != nil {
+				t.Fatalf("Report() error = %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("Report() = %q, want %q", out.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewTimeHistogram_InvalidBucket(t *testing.T) {
+	if _, err := patt.NewTimeHistogram("ts", "", 0, false); err == nil {
+		t.Error("NewTimeHistogram() with zero bucket should fail")
+	}
+}
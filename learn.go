@@ -0,0 +1,245 @@
+package patt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"patt/pattern"
+)
+
+const defaultLearnLines = 1000
+
+// ErrNoExamples is returned by Learn without example lines.
+var ErrNoExamples = errors.New("no example lines")
+
+// tokenDelimiters separate the words of a line, and are tokens themselves.
+const tokenDelimiters = " \t[](){}<>\"',;="
+
+// tokenize splits a line into words and single delimiter characters.
+func tokenize(line []byte) []string {
+	var tokens []string
+	start := 0
+	for i, c := range line {
+		if strings.IndexByte(tokenDelimiters, c) < 0 {
+			continue
+		}
+		if start < i {
+			tokens = append(tokens, string(line[start:i]))
+		}
+		tokens = append(tokens, string(c))
+		start = i + 1
+	}
+	if start < len(line) {
+		tokens = append(tokens, string(line[start:]))
+	}
+	return tokens
+}
+
+// lcs returns the longest common subsequence of a and b.
+func lcs(a, b []string) []string {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+	var common []string
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			common = append(common, a[i])
+			i, j = i+1, j+1
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return common
+}
+
+// gaps returns the text of the line before, between and after the stable
+// tokens, which are matched like the pattern matcher does, at their first
+// occurrence. It returns false if the stable tokens are not all found.
+func gaps(stable, tokens []string) ([]string, bool) {
+	result := make([]string, 0, len(stable)+1)
+	var gap strings.Builder
+	j := 0
+	for _, s := range stable {
+		for j < len(tokens) && tokens[j] != s {
+			gap.WriteString(tokens[j])
+			j++
+		}
+		if j == len(tokens) {
+			return nil, false
+		}
+		result = append(result, gap.String())
+		gap.Reset()
+		j++
+	}
+	for ; j < len(tokens); j++ {
+		gap.WriteString(tokens[j])
+	}
+	return append(result, gap.String()), true
+}
+
+// Learn infers a pattern matching all the lines. Tokens found in all the lines
+// become literals, and the varying text between them named captures <field1>,
+// <field2>, ...
+func Learn(lines [][]byte) (string, error) {
+	if len(lines) == 0 {
+		return "", ErrNoExamples
+	}
+	tokens := make([][]string, len(lines))
+	for i, line := range lines {
+		tokens[i] = tokenize(line)
+	}
+	stable := tokens[0]
+	for _, t := range tokens[1:] {
+		stable = lcs(stable, t)
+	}
+
+	// Turn stable tokens into captured text until no capture is empty and no
+	// literal is found in the text captured before it.
+	for {
+		lineGaps := make([][]string, len(tokens))
+		for i, t := range tokens {
+			g, ok := gaps(stable, t)
+			if !ok {
+				return "", fmt.Errorf("cannot align line %d", i+1)
+			}
+			lineGaps[i] = g
+		}
+		isCapture := make([]bool, len(stable)+1)
+		for _, g := range lineGaps {
+			for p, text := range g {
+				isCapture[p] = isCapture[p] || text != ""
+			}
+		}
+		drop := -1
+	check:
+		for p := range isCapture {
+			if !isCapture[p] {
+				continue
+			}
+			literal := literalAt(stable, isCapture, p)
+			for _, g := range lineGaps {
+				if g[p] == "" {
+					// Capture the stable token before the empty capture, or
+					// the one after it at the start of the line.
+					drop = max(p-1, 0)
+					break check
+				}
+				if literal != "" && strings.Contains(g[p]+literal[:len(literal)-1], literal) {
+					// The literal would end the capture too early, capture it.
+					drop = p
+					break check
+				}
+			}
+		}
+		if drop < 0 || drop >= len(stable) {
+			return buildPattern(lines, stable, isCapture)
+		}
+		stable = append(stable[:drop:drop], stable[drop+1:]...)
+	}
+}
+
+// literalAt returns the literal following the capture at position p.
+func literalAt(stable []string, isCapture []bool, p int) string {
+	var literal strings.Builder
+	for i := p; i < len(stable); i++ {
+		literal.WriteString(stable[i])
+		if isCapture[i+1] {
+			break
+		}
+	}
+	return literal.String()
+}
+
+func buildPattern(lines [][]byte, stable []string, isCapture []bool) (string, error) {
+	var b strings.Builder
+	captures := 0
+	for p := range isCapture {
+		if isCapture[p] {
+			captures++
+			fmt.Fprintf(&b, "<field%d>", captures)
+		}
+		if p < len(stable) {
+			b.WriteString(stable[p])
+		}
+	}
+	p := b.String()
+	m, err := pattern.New(p)
+	if err != nil {
+		return "", fmt.Errorf("invalid learned pattern '%s': %w", p, err)
+	}
+	if len(m.Names()) != captures {
+		return "", fmt.Errorf("learned pattern '%s' has literals parsed as captures", p)
+	}
+	for i, line := range lines {
+		if !m.Test(line) {
+			return "", fmt.Errorf("learned pattern '%s' does not match line %d", p, i+1)
+		}
+	}
+	return p, nil
+}
+
+// runLearn implements `patt learn [-n lines] [file]`, which prints a pattern
+// learned from the example lines of the file or of stdin.
+func runLearn(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	var numLines int
+	cmd := &cobra.Command{
+		Use:  "patt learn [-n lines] [file]",
+		Args: cobra.MaximumNArgs(1),
+	}
+	cmd.Flags().IntVarP(&numLines, "lines", "n", defaultLearnLines, "maximum number of example lines read")
+	if err := cmd.ParseFlags(args); err != nil {
+		return fmt.Errorf("bad parameters: %w", err)
+	}
+	if err := cmd.Args(cmd, cmd.Flags().Args()); err != nil {
+		return fmt.Errorf("bad parameters: %w", err)
+	}
+	if numLines <= 0 {
+		return errors.New("bad parameters: the number of lines must be positive")
+	}
+
+	r := io.NopCloser(stdin)
+	if files := cmd.Flags().Args(); len(files) == 1 {
+		var err error
+		r, err = (&BufferedFileOpener{}).Open(files[0])
+		if err != nil {
+			return fmt.Errorf("cannot open input file: %w", err)
+		}
+	}
+	defer r.Close()
+	lines, err := readLines(ctx, r, numLines)
+	if err != nil {
+		return fmt.Errorf("cannot read lines: %w", err)
+	}
+	examples := lines[:0]
+	for _, line := range lines {
+		if len(bytes.TrimSpace(line)) > 0 {
+			examples = append(examples, line)
+		}
+	}
+
+	p, err := Learn(examples)
+	if err != nil {
+		return fmt.Errorf("cannot learn a pattern: %w", err)
+	}
+	_, err = fmt.Fprintln(stdout, p)
+	return err
+}
@@ -0,0 +1,89 @@
+package patt_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+func TestLearn(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		expect string
+	}{
+		{
+			name: "access log",
+			lines: []string{
+				`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+				`10.0.0.2 - - [04/Dec/2005:04:47:44 +0000] "POST /login HTTP/1.1" 302 -`,
+			},
+			expect: `<field1> - <field2> [<field3> <field4>] "<field5> <field6> <field7>" <field8> <field9>`,
+		},
+		{
+			name:   "stable words",
+			lines:  []string{"[error] disk full on sda", "[error] disk full on sdb1"},
+			expect: "[error] disk full on <field1>",
+		},
+		{
+			name:   "optional token",
+			lines:  []string{"a b", "a x b"},
+			expect: "a<field1>b",
+		},
+		{
+			name:   "literal inside a capture",
+			lines:  []string{"user=1 ok", "user=22 ok", "user=1 1 ok"},
+			expect: "user=<field1>ok",
+		},
+		{
+			name:   "identical lines",
+			lines:  []string{"same line", "same line"},
+			expect: "same line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := make([][]byte, len(tt.lines))
+			for i, l := range tt.lines {
+				lines[i] = []byte(l)
+			}
+
+			got, err := patt.Learn(lines)
+			if err != nil {
+				t.Fatalf("Learn() error = %v", err)
+			}
+			if got != tt.expect {
+				t.Errorf("Learn() = %q, want %q", got, tt.expect)
+			}
+			filter, err := patt.NewFilter(got)
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+			for _, l := range lines {
+				if !filter.Match(l) {
+					t.Errorf("line %q is not matched", l)
+				}
+			}
+		})
+	}
+
+	if _, err := patt.Learn(nil); !errors.Is(err, patt.ErrNoExamples) {
+		t.Errorf("Learn(nil) error = %v, want ErrNoExamples", err)
+	}
+}
+
+func TestRunCLI_Learn(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	err := patt.RunCLI(context.Background(), []string{"patt", "learn", "testdata/Apache_3.log.gz"}, strings.NewReader(""), stdout, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	if want := "[Sun Dec 04 <field1> 2005] [<field2>] <field3> <field4> <field5>\n"; stdout.String() != want {
+		t.Errorf("expected output %q, got %q", want, stdout.String())
+	}
+}
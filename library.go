@@ -0,0 +1,162 @@
+package patt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"patt/pattern"
+)
+
+// PatternLibrary maps names to the search patterns and template they expand to.
+type PatternLibrary map[string]PatternsFile
+
+// ReadPatternLibrary reads named patterns from config files, a later file
+// replacing the entries of the same name of the previous ones. Missing files
+// are ignored. A config file is made of sections like:
+//
+//	# Comment.
+//	[apache-error]
+//	pattern = [<date>] [error] <message>
+//	pattern = [<date>] [crit] <message>
+//	template = <date> <message>
+//
+// Each section has one or more patterns and at most one template.
+func ReadPatternLibrary(names ...string) (PatternLibrary, error) {
+	library := PatternLibrary{}
+	for _, name := range names {
+		entries, err := readLibraryFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for entry, pf := range entries {
+			library[entry] = pf
+		}
+	}
+	return library, nil
+}
+
+func readLibraryFile(name string) (PatternLibrary, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := PatternLibrary{}
+	entry, entryLine := "", 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, DefaultMaxLineLength)
+	lineNo := 0
+	fail := func(err error) (PatternLibrary, error) {
+		return nil, &PatternsFileError{Name: name, Line: lineNo, Err: err}
+	}
+	checkEntry := func() error {
+		if entry != "" && len(entries[entry].Patterns) == 0 {
+			return fmt.Errorf("no pattern in [%s] at line %d", entry, entryLine)
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		lineNo++
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if err := checkEntry(); err != nil {
+				return fail(err)
+			}
+			entry, entryLine = strings.TrimSpace(line[1:len(line)-1]), lineNo
+			if entry == "" {
+				return fail(errors.New("empty pattern name"))
+			}
+			if _, ok := entries[entry]; ok {
+				return fail(fmt.Errorf("duplicate pattern name [%s]", entry))
+			}
+			entries[entry] = PatternsFile{}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fail(fmt.Errorf("expected [name] or key = value, got '%s'", line))
+		}
+		if entry == "" {
+			return fail(errors.New("key outside of a [name] section"))
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		pf := entries[entry]
+		switch key {
+		case "pattern":
+			if _, err := pattern.New(value); err != nil {
+				return nil, newPatternsFileError(name, lineNo, strings.Index(raw, value), err)
+			}
+			pf.Patterns = append(pf.Patterns, value)
+		case "template":
+			if pf.Template != "" {
+				return fail(fmt.Errorf("duplicate template in [%s]", entry))
+			}
+			pf.Template = value
+		default:
+			return fail(fmt.Errorf("unknown key '%s', expected pattern or template", key))
+		}
+		entries[entry] = pf
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := checkEntry(); err != nil {
+		return fail(err)
+	}
+	return entries, nil
+}
+
+// LibraryPaths returns the config files of the pattern library, by increasing
+// priority: the user config, $XDG_CONFIG_HOME/patt/patterns or
+// ~/.config/patt/patterns, then the .patt/patterns files of dir and its
+// parents, the nearest last.
+func LibraryPaths(dir string) []string {
+	var paths []string
+	if config, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(config, "patt", "patterns"))
+	}
+	var project []string
+	for dir != "" {
+		project = append(project, filepath.Join(dir, ".patt", "patterns"))
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	for i := len(project) - 1; i >= 0; i-- {
+		paths = append(paths, project[i])
+	}
+	return paths
+}
+
+// usePatterns adds the patterns of the library entry params.Use, or else of
+// the bundled format, to params like loadPatternsFiles.
+func usePatterns(params *CLIParams, library PatternLibrary) error {
+	pf, ok := library[params.Use]
+	if !ok {
+		f, ok := LookupFormat(params.Use)
+		if !ok {
+			return fmt.Errorf("unknown pattern name '%s'", params.Use)
+		}
+		pf = PatternsFile{Patterns: f.Patterns}
+	}
+	params.SearchPatterns = append(append([]string(nil), pf.Patterns...), params.SearchPatterns...)
+	if params.ReplaceTemplate == "" {
+		params.ReplaceTemplate = pf.Template
+	}
+	return nil
+}
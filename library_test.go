@@ -0,0 +1,188 @@
+package patt_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+func TestReadPatternLibrary(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user")
+	writeFile(t, user, "# User patterns.\n"+
+		"[apache-error]\n"+
+		"pattern = [<date>] [error] <message>\n"+
+		"template = <message>\n"+
+		"\n"+
+		"[nginx]\n"+
+		"pattern = <ip> - <_>\n")
+	project := filepath.Join(dir, "project")
+	writeFile(t, project, "; Project patterns.\n"+
+		"[apache-error]\n"+
+		"  pattern = [<date>] [error] <message>\n"+
+		"  pattern = [<date>] [crit] <message>\n"+
+		"  template = <date>: <message>\n")
+
+	got, err := patt.ReadPatternLibrary(user, filepath.Join(dir, "missing"), project)
+	if err != nil {
+		t.Fatalf("ReadPatternLibrary() error = %v", err)
+	}
+	want := patt.PatternLibrary{
+		"apache-error": {
+			Patterns: []string{"[<date>] [error] <message>", "[<date>] [crit] <message>"},
+			Template: "<date>: <message>",
+		},
+		"nginx": {Patterns: []string{"<ip> - <_>"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadPatternLibrary() = %#v, want %#v", got, want)
+	}
+}
+
+func TestReadPatternLibrary_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		expectErr string
+	}{
+		{
+			name:      "key outside section",
+			content:   "pattern = <a>\n",
+			expectErr: ":1: key outside of a [name] section",
+		},
+		{
+			name:      "unknown key",
+			content:   "[a]\npattern = <a>\nformat = <a>\n",
+			expectErr: ":3: unknown key 'format', expected pattern or template",
+		},
+		{
+			name:      "invalid pattern",
+			content:   "[a]\npattern = <a><b>\n",
+			expectErr: ":2: found consecutive capture '<a><b>': invalid expression",
+		},
+		{
+			name:      "duplicate capture",
+			content:   "[a]\npattern = <a>\npattern = <a> - <a>\n",
+			expectErr: ":3: duplicate capture name (a): invalid expression",
+		},
+		{
+			name:      "section without pattern",
+			content:   "[a]\ntemplate = <a>\n[b]\npattern = <b>\n",
+			expectErr: ":3: no pattern in [a] at line 1",
+		},
+		{
+			name:      "duplicate section",
+			content:   "[a]\npattern = <a>\n[a]\n",
+			expectErr: ":3: duplicate pattern name [a]",
+		},
+		{
+			name:      "not a key value",
+			content:   "[a]\n<a>\n",
+			expectErr: ":2: expected [name] or key = value, got '<a>'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "patterns")
+			writeFile(t, name, tt.content)
+
+			_, err := patt.ReadPatternLibrary(name)
+			if err == nil {
+				t.Fatal("ReadPatternLibrary() should fail")
+			}
+			if want := name + tt.expectErr; err.Error() != want {
+				t.Errorf("ReadPatternLibrary() error = %q, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestLibraryPaths(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/config")
+
+	got := patt.LibraryPaths("/home/user/project")
+	want := []string{
+		"/config/patt/patterns",
+		"/.patt/patterns",
+		"/home/.patt/patterns",
+		"/home/user/.patt/patterns",
+		"/home/user/project/.patt/patterns",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LibraryPaths() = %v, want %v", got, want)
+	}
+}
+
+func TestRunCLI_Use(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	project := t.TempDir()
+	sub := filepath.Join(project, "sub")
+	for _, dir := range []string{filepath.Join(config, "patt"), filepath.Join(project, ".patt"), sub} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(config, "patt", "patterns"), "[apache-error]\n"+
+		"pattern = [<date>] [error] <message>\n"+
+		"template = user: <message>\n")
+	writeFile(t, filepath.Join(project, ".patt", "patterns"), "[apache-error]\n"+
+		"pattern = [<date>] [error] <message>\n"+
+		"template = project: <message>\n")
+	input, err := filepath.Abs("testdata/Apache_3.log.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(sub)
+
+	tests := []struct {
+		name      string
+		args      []string
+		expectErr string
+		expectOut string
+	}{
+		{
+			name:      "project overrides user",
+			args:      []string{"patt", "--use", "apache-error", "--", input},
+			expectOut: "project: mod_jk child workerEnv in error state 6\n",
+		},
+		{
+			name:      "template argument",
+			args:      []string{"patt", "--use", "apache-error", "<date>", "--", input},
+			expectOut: "Sun Dec 04 04:47:44 2005\n",
+		},
+		{
+			name:      "unknown name",
+			args:      []string{"patt", "--use", "nginx", "--", input},
+			expectErr: "unknown pattern name 'nginx'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+
+			err := patt.RunCLI(context.Background(), tt.args, strings.NewReader(""), stdout, &bytes.Buffer{})
+
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Errorf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RunCLI() error = %v", err)
+			}
+			if stdout.String() != tt.expectOut {
+				t.Errorf("expected output %q, got %q", tt.expectOut, stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package patt
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// Line filter operators, as in LogQL.
+const (
+	OpContains    = "|="
+	OpNotContains = "!="
+	OpRegex       = "|~"
+	OpNotRegex    = "!~"
+)
+
+// lineFilter checks whether lines contain a string or match a regular
+// expression, or not.
+type lineFilter struct {
+	op     string
+	value  string
+	substr []byte
+	re     *regexp.Regexp
+}
+
+// NewLineFilter creates a matcher of the lines matched by the LogQL line
+// filter operator op and value: |= and != check that lines contain the value
+// or not, |~ and !~ that they match the regular expression value or not.
+func NewLineFilter(op, value string) (LinesMatcher, error) {
+	f := &lineFilter{op: op, value: value}
+	switch op {
+	case OpContains, OpNotContains:
+		f.substr = []byte(value)
+	case OpRegex, OpNotRegex:
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression '%s': %w", value, err)
+		}
+		f.re = re
+	default:
+		return nil, fmt.Errorf("unknown line filter operator '%s'", op)
+	}
+	return f, nil
+}
+
+func (f *lineFilter) Match(line []byte) bool {
+	switch f.op {
+	case OpContains:
+		return bytes.Contains(line, f.substr)
+	case OpNotContains:
+		return !bytes.Contains(line, f.substr)
+	case OpRegex:
+		return f.re.Match(line)
+	}
+	return !f.re.Match(line)
+}
+
+func (f *lineFilter) String() string {
+	return fmt.Sprintf("%s %q", f.op, f.value)
+}
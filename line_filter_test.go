@@ -0,0 +1,85 @@
+package patt_test
+
+import (
+	"testing"
+
+	"patt"
+)
+
+func TestNewLineFilter(t *testing.T) {
+	tests := []struct {
+		op, value string
+		want      []bool
+		wantErr   bool
+	}{
+		{op: patt.OpContains, value: "error", want: []bool{true, false, true}},
+		{op: patt.OpNotContains, value: "error", want: []bool{false, true, false}},
+		{op: patt.OpRegex, value: `status=5\d\d`, want: []bool{true, false, false}},
+		{op: patt.OpNotRegex, value: `status=5\d\d`, want: []bool{false, true, true}},
+		{op: patt.OpContains, value: "", want: []bool{true, true, true}},
+		{op: patt.OpRegex, value: "(", wantErr: true},
+		{op: "=~", value: "error", wantErr: true},
+	}
+
+	lines := []string{"error status=500", "info status=200", "errors status=404"}
+	for _, tt := range tests {
+		t.Run(tt.op+" "+tt.value, func(t *testing.T) {
+			f, err := patt.NewLineFilter(tt.op, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewLineFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for i, line := range lines {
+				if got := f.Match([]byte(line)); got != tt.want[i] {
+					t.Errorf("Match(%q) = %v, want %v", line, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMultiReplacer_LineFilters(t *testing.T) {
+	contains, err := patt.NewLineFilter(patt.OpContains, "error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notRegex, err := patt.NewLineFilter(patt.OpNotRegex, "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := patt.NewMultiReplacer([]string{"<level> <path> <status>"}, "<path>", patt.WithLineFilters(contains, notRegex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "error /api 500", want: "/api"},
+		{line: "info /api 200"},
+		{line: "error /health 500"},
+	}
+	for _, tt := range tests {
+		matched := r.Match([]byte(tt.line))
+		if matched != (tt.want != "") {
+			t.Errorf("Match(%q) = %v", tt.line, matched)
+			continue
+		}
+		if !matched {
+			explanations := r.Explain([]byte(tt.line))
+			if len(explanations) != 1 || explanations[0].Mismatch.Reason != "the line is filtered out" {
+				t.Errorf("Explain(%q) = %v, want a filtered out line", tt.line, explanations)
+			}
+			continue
+		}
+		if got := string(r.Replace([]byte(tt.line))); got != tt.want {
+			t.Errorf("Replace(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+	// The pattern is only tried on the lines kept by the filters.
+	if got := r.Stats(); got.Lines != 3 || got.Tries != 1 {
+		t.Errorf("Stats() = %+v, want 3 lines and 1 try", got)
+	}
+}
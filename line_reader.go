@@ -0,0 +1,155 @@
+package patt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// LongLinePolicy defines what happens to lines longer than the maximum line length.
+type LongLinePolicy int
+
+const (
+	// LongLinesFail stops processing with a LineTooLongError.
+	LongLinesFail LongLinePolicy = iota
+	// LongLinesTruncate processes the first bytes of the line, up to the maximum length.
+	LongLinesTruncate
+	// LongLinesSkip ignores the line, writing a warning if a warnings writer is configured.
+	LongLinesSkip
+)
+
+var longLinePolicies = map[string]LongLinePolicy{
+	"fail":     LongLinesFail,
+	"truncate": LongLinesTruncate,
+	"skip":     LongLinesSkip,
+}
+
+// ParseLongLinePolicy parses the policy names accepted by --long-lines.
+func ParseLongLinePolicy(s string) (LongLinePolicy, error) {
+	policy, ok := longLinePolicies[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown long lines policy '%s', expected fail, truncate or skip", s)
+	}
+	return policy, nil
+}
+
+// DefaultMaxLineLength is the maximum line length when none is configured.
+const DefaultMaxLineLength = 16 * 1024 * 1024
+
+const lineReaderBufSize = 64 * 1024
+
+// LineTooLongError is returned when a line exceeds the maximum line length
+// with the LongLinesFail policy.
+type LineTooLongError struct {
+	Line      int
+	MaxLength int
+}
+
+func (e *LineTooLongError) Error() string {
+	return fmt.Sprintf("line %d is longer than the maximum line length of %d bytes", e.Line, e.MaxLength)
+}
+
+// lineReader reads lines of any length, keeping at most maxLength bytes of a
+// line in memory. Like bufio.ScanLines, it strips the line terminator
+// including a carriage return before the newline.
+type lineReader struct {
+	r         *bufio.Reader
+	maxLength int
+	policy    LongLinePolicy
+	warnings  io.Writer
+	buf       []byte
+	// line counts the lines read, numbered from firstLine() in messages.
+	line      int
+	firstLine func() int
+}
+
+func newLineReader(r io.Reader, maxLength int, policy LongLinePolicy, warnings io.Writer) *lineReader {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxLineLength
+	}
+	return &lineReader{
+		r:         bufio.NewReaderSize(r, lineReaderBufSize),
+		maxLength: maxLength,
+		policy:    policy,
+		warnings:  warnings,
+	}
+}
+
+// next returns the next line, which is only valid until the following call,
+// or io.EOF at the end of the input.
+func (lr *lineReader) next() ([]byte, error) {
+	for {
+		line, tooLong, err := lr.readLine()
+		if err != nil {
+			return nil, err
+		}
+		lr.line++
+		if !tooLong {
+			return dropCR(line), nil
+		}
+		switch lr.policy {
+		case LongLinesTruncate:
+			return line, nil
+		case LongLinesSkip:
+			if lr.warnings != nil {
+				fmt.Fprintf(lr.warnings, "patt: skipping line %d, longer than %d bytes\n", lr.number(), lr.maxLength)
+			}
+			continue
+		default:
+			return nil, &LineTooLongError{Line: lr.number(), MaxLength: lr.maxLength}
+		}
+	}
+}
+
+// number returns the number of the last line read in its file.
+func (lr *lineReader) number() int {
+	if lr.firstLine == nil {
+		return lr.line
+	}
+	return lr.firstLine() - 1 + lr.line
+}
+
+// readLine reads up to the next newline. When the line is longer than
+// maxLength, only its first maxLength bytes are returned and the rest is discarded.
+func (lr *lineReader) readLine() ([]byte, bool, error) {
+	chunk, err := lr.r.ReadSlice('\n')
+	if err == nil && len(chunk)-1 <= lr.maxLength {
+		// Fast path, the whole line fits in the reader buffer.
+		return chunk[:len(chunk)-1], false, nil
+	}
+	lr.buf = lr.buf[:0]
+	tooLong := false
+	for {
+		data := chunk
+		if err == nil {
+			data = chunk[:len(chunk)-1]
+		}
+		if room := lr.maxLength - len(lr.buf); len(data) > room {
+			lr.buf = append(lr.buf, data[:room]...)
+			tooLong = true
+		} else {
+			lr.buf = append(lr.buf, data...)
+		}
+		switch {
+		case err == nil:
+			return lr.buf, tooLong, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			chunk, err = lr.r.ReadSlice('\n')
+		case err == io.EOF:
+			if len(lr.buf) == 0 && !tooLong {
+				return nil, false, io.EOF
+			}
+			return lr.buf, tooLong, nil
+		default:
+			return nil, false, err
+		}
+	}
+}
+
+func dropCR(line []byte) []byte {
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		return line[:len(line)-1]
+	}
+	return line
+}
@@ -0,0 +1,95 @@
+package patt_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+func TestLineProcessor_LongLines(t *testing.T) {
+	long := strings.Repeat("a", 200*1024)
+	tests := []struct {
+		name             string
+		input            string
+		opts             []patt.LineProcessorOption
+		expected         string
+		expectedWarnings string
+		expectErr        bool
+	}{
+		{
+			name:     "line longer than the read buffer",
+			input:    "short\n" + long + "\nshort\n",
+			expected: "short\n" + long + "\nshort\n",
+		},
+		{
+			name:     "line longer than the read buffer without newline",
+			input:    "short\n" + long,
+			expected: "short\n" + long + "\n",
+		},
+		{
+			name:     "carriage returns are dropped",
+			input:    "one\r\ntwo\r\n",
+			expected: "one\ntwo\n",
+		},
+		{
+			name:     "truncate",
+			input:    "0123456789\n" + long + "\n01234\n",
+			opts:     []patt.LineProcessorOption{patt.WithMaxLineLength(8, patt.LongLinesTruncate)},
+			expected: "01234567\naaaaaaaa\n01234\n",
+		},
+		{
+			name:             "skip",
+			input:            "0123456789\n" + long + "\n01234\n",
+			opts:             []patt.LineProcessorOption{patt.WithMaxLineLength(10, patt.LongLinesSkip)},
+			expected:         "0123456789\n01234\n",
+			expectedWarnings: "patt: skipping line 2, longer than 10 bytes\n",
+		},
+		{
+			name:      "fail",
+			input:     "0123456789\n" + long + "\n01234\n",
+			opts:      []patt.LineProcessorOption{patt.WithMaxLineLength(10, patt.LongLinesFail)},
+			expected:  "0123456789\n",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, warnings bytes.Buffer
+			opts := append([]patt.LineProcessorOption{patt.WithWarnings(&warnings)}, tt.opts...)
+			processor := patt.NewLineProcessor(makeMatcher(t, "<_>"), false, opts...)
+
+			_, err := processor.Process(context.Background(), strings.NewReader(tt.input), &out)
+			var tooLong *patt.LineTooLongError
+			if tt.expectErr != errors.As(err, &tooLong) {
+				t.Errorf("Process() error = %v, expected LineTooLongError: %v", err, tt.expectErr)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected output %q but got %q", tt.expected, out.String())
+			}
+			if warnings.String() != tt.expectedWarnings {
+				t.Errorf("expected warnings %q but got %q", tt.expectedWarnings, warnings.String())
+			}
+		})
+	}
+}
+
+func TestParseLongLinePolicy(t *testing.T) {
+	for name, expected := range map[string]patt.LongLinePolicy{
+		"fail":     patt.LongLinesFail,
+		"truncate": patt.LongLinesTruncate,
+		"skip":     patt.LongLinesSkip,
+	} {
+		got, err := patt.ParseLongLinePolicy(name)
+		if err != nil || got != expected {
+			t.Errorf("ParseLongLinePolicy(%q) = %v, %v, want %v", name, got, err, expected)
+		}
+	}
+	if _, err := patt.ParseLongLinePolicy("wrap"); err == nil {
+		t.Error("ParseLongLinePolicy(\"wrap\") should fail")
+	}
+}
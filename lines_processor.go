@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"io"
+	"strconv"
 )
 
 type LineProcessor interface {
@@ -13,51 +14,231 @@ type LineProcessor interface {
 type lineProcessor struct {
 	keepNonMatching bool
 	replacer        LineReplacer
+	aggregator      Aggregator
+	lineBuffered    bool
+	maxLineLength   int
+	longLines       LongLinePolicy
+	warnings        io.Writer
+	explain         *explainer
+	coverage        *Coverage
+	allMatches      bool
+	where           *Where
 }
 
-func NewLineProcessor(replacer LineReplacer, keepNonMatching bool) LineProcessor {
-	return &lineProcessor{
+// LineProcessorOption configures optional behaviour of the processor returned by NewLineProcessor.
+type LineProcessorOption func(*lineProcessor)
+
+// WithAggregator makes the processor feed the captures of matching lines to
+// the aggregator instead of writing them.
+func WithAggregator(aggregator Aggregator) LineProcessorOption {
+	return func(p *lineProcessor) {
+		p.aggregator = aggregator
+	}
+}
+
+// WithLineBuffering flushes the output after every line, so that lines
+// are visible as soon as they are read when following a file.
+func WithLineBuffering() LineProcessorOption {
+	return func(p *lineProcessor) {
+		p.lineBuffered = true
+	}
+}
+
+// WithMaxLineLength sets the maximum length of a line, and what to do with
+// longer lines. By default lines longer than DefaultMaxLineLength fail the processing.
+func WithMaxLineLength(maxLength int, policy LongLinePolicy) LineProcessorOption {
+	return func(p *lineProcessor) {
+		p.maxLineLength = maxLength
+		p.longLines = policy
+	}
+}
+
+// WithWarnings sets where warnings about skipped lines are written.
+func WithWarnings(w io.Writer) LineProcessorOption {
+	return func(p *lineProcessor) {
+		p.warnings = w
+	}
+}
+
+// WithAllMatches makes every pattern matching a line produce its own output
+// line, prefixed by the index of the pattern and a tab, or its own captures
+// for the aggregator. The replacer must be a MultiReplacer with WithAllPatterns.
+func WithAllMatches() LineProcessorOption {
+	return func(p *lineProcessor) {
+		p.allMatches = true
+	}
+}
+
+// WithWhere only outputs or aggregates the matching lines whose captures
+// satisfy the condition, the other lines being non-matching lines.
+func WithWhere(w *Where) LineProcessorOption {
+	return func(p *lineProcessor) {
+		p.where = w
+	}
+}
+
+// allMatcher is implemented by replacers with several matching patterns per line.
+type allMatcher interface {
+	MatchedPatterns() []int
+	ReplacePattern(i int, line []byte) []byte
+	PatternCaptures(i int, line []byte) Captures
+}
+
+func NewLineProcessor(replacer LineReplacer, keepNonMatching bool, opts ...LineProcessorOption) LineProcessor {
+	p := &lineProcessor{
 		keepNonMatching: keepNonMatching,
 		replacer:        replacer,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Clone returns a processor sharing the configuration of p, with its own
+// replacer state, so that it can be used concurrently with p.
+// The aggregator is shared, and must be safe for concurrent use.
+func (p *lineProcessor) Clone() LineProcessor {
+	c := *p
+	c.replacer = cloneReplacer(p.replacer)
+	return &c
 }
 
 const contextCheckInterval = 1000
 
 func (p *lineProcessor) Process(ctx context.Context, r io.Reader, w io.Writer) (bool, error) {
-	scanner := bufio.NewScanner(r)
+	return p.processFrom(ctx, "", nil, r, w)
+}
+
+// processFrom processes r, numbering its lines from firstLine() in errors,
+// warnings and explanations, e.g. for a chunk of a file. firstLine is only
+// called to report a line, and nil stands for 1. Explanations are prefixed
+// by the name of the source, unless empty.
+func (p *lineProcessor) processFrom(ctx context.Context, name string, firstLine func() int, r io.Reader, w io.Writer) (bool, error) {
+	reader := newLineReader(r, p.maxLineLength, p.longLines, p.warnings)
+	reader.firstLine = firstLine
 	writer := bufio.NewWriter(w)
 	defer writer.Flush()
 
 	var match bool
-	lines := 0
-	for scanner.Scan() {
-		lines++
-		if lines%contextCheckInterval == 0 {
+	for {
+		line, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+		if reader.line%contextCheckInterval == 0 {
 			select {
 			case <-ctx.Done():
 				return false, ctx.Err()
 			default:
 			}
 		}
-		line := scanner.Bytes()
-		if p.replacer.Match(line) {
-			line = p.replacer.Replace(line)
+		matched := p.replacer.Match(line)
+		var all allMatcher
+		if a, ok := p.replacer.(allMatcher); ok && p.allMatches {
+			all = a
+		}
+		var patterns []int
+		whereFalse := false
+		switch {
+		case matched && all != nil:
+			patterns = p.wherePatterns(all, line)
+			matched = len(patterns) > 0
+			whereFalse = !matched
+		case matched && p.where != nil:
+			matched = p.where.Match(p.replacer.Captures(line))
+			whereFalse = !matched
+		}
+		if matched {
 			match = true
-		} else if !p.keepNonMatching {
-			continue
+			if all != nil {
+				if err := p.processAll(all, patterns, line, writer); err != nil {
+					return false, err
+				}
+				continue
+			}
+			if p.coverage != nil {
+				p.coverage.addMatched(matchedPattern(p.replacer))
+			}
+			if p.aggregator != nil {
+				p.aggregator.Add(p.replacer.Captures(line))
+				continue
+			}
+			line = p.replacer.Replace(line)
+		} else {
+			if p.coverage != nil {
+				p.coverage.addUnmatched(line)
+			}
+			if p.explain != nil {
+				explanations := func() []Explanation { return explain(p.replacer, line) }
+				if whereFalse {
+					explanations = func() []Explanation {
+						return []Explanation{p.where.explain(p.replacer.Captures(line))}
+					}
+				}
+				if err := p.explain.explain(name, reader.number(), line, explanations); err != nil {
+					return false, err
+				}
+			}
+			if !p.keepNonMatching || p.aggregator != nil {
+				continue
+			}
 		}
 		if err := writeLine(writer, line); err != nil {
 			return false, err
 		}
+		if p.lineBuffered {
+			if err := writer.Flush(); err != nil {
+				return false, err
+			}
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return false, err
-	}
 	return match, nil
 }
 
+// wherePatterns returns the matching patterns of the line whose captures
+// satisfy the where condition.
+func (p *lineProcessor) wherePatterns(all allMatcher, line []byte) []int {
+	if p.where == nil {
+		return all.MatchedPatterns()
+	}
+	var patterns []int
+	for _, i := range all.MatchedPatterns() {
+		if p.where.Match(all.PatternCaptures(i, line)) {
+			patterns = append(patterns, i)
+		}
+	}
+	return patterns
+}
+
+// processAll outputs or aggregates the line for each of the matching patterns.
+func (p *lineProcessor) processAll(all allMatcher, patterns []int, line []byte, writer *bufio.Writer) error {
+	if p.coverage != nil {
+		p.coverage.addMatched(patterns...)
+	}
+	var tagged []byte
+	for _, i := range patterns {
+		if p.aggregator != nil {
+			p.aggregator.Add(all.PatternCaptures(i, line))
+			continue
+		}
+		tagged = strconv.AppendInt(tagged[:0], int64(i), 10)
+		tagged = append(tagged, '\t')
+		tagged = append(tagged, all.ReplacePattern(i, line)...)
+		if err := writeLine(writer, tagged); err != nil {
+			return err
+		}
+	}
+	if p.lineBuffered && p.aggregator == nil {
+		return writer.Flush()
+	}
+	return nil
+}
+
 func writeLine(w *bufio.Writer, line []byte) error {
 	if _, err := w.Write(line); err != nil {
 		return err
@@ -0,0 +1,248 @@
+package patt
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// LogQLLineFilter is a line filter stage of a LogQL pipeline, such as |= "error".
+type LogQLLineFilter struct {
+	Op    string
+	Value string
+}
+
+// LogQLPipeline holds the stages of a LogQL pipeline in the order patt runs
+// them: the line filters, the pattern, the label filters and the line format.
+type LogQLPipeline struct {
+	LineFilters []LogQLLineFilter
+	// Pattern is the pattern of the pattern stage, if any.
+	Pattern      string
+	LabelFilters []string
+	// LineFormat is the template of the line_format stage, if any.
+	LineFormat string
+}
+
+// ParseLogQL parses a subset of the LogQL pipeline stages:
+//
+//	|= "text"  != "text"  |~ "regex"  !~ "regex"
+//	| pattern "<ip> - <_>"
+//	| status >= 500 and latency > 250ms
+//	| line_format "{{.ip}}"
+//
+// A leading stream selector such as {app="api"} is ignored, since local files
+// have no labels. Label filters must follow the pattern stage, and line
+// filters precede the line_format stage, which must be last.
+func ParseLogQL(s string) (*LogQLPipeline, error) {
+	stages, err := splitPipeline(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LogQL pipeline: %w", err)
+	}
+	p := &LogQLPipeline{}
+	for i, stage := range stages {
+		if err := p.addStage(i, stage); err != nil {
+			return nil, fmt.Errorf("invalid LogQL pipeline: %w", err)
+		}
+	}
+	return p, nil
+}
+
+func (p *LogQLPipeline) addStage(i int, stage string) error {
+	if i == 0 {
+		// Before the first |: an optional stream selector and line filters.
+		stage = strings.TrimSpace(stage)
+		if strings.HasPrefix(stage, "{") {
+			end, err := selectorEnd(stage)
+			if err != nil {
+				return err
+			}
+			stage = stage[end+1:]
+		}
+		if strings.TrimSpace(stage) == "" {
+			return nil
+		}
+		return p.addLineFilters(stage)
+	}
+	if p.LineFormat != "" {
+		return fmt.Errorf("stage '|%s' after line_format is not supported", stage)
+	}
+	if strings.HasPrefix(stage, "=") || strings.HasPrefix(stage, "~") {
+		return p.addLineFilters("|" + stage)
+	}
+
+	name, arg, _ := strings.Cut(strings.TrimSpace(stage), " ")
+	switch name {
+	case "pattern":
+		if p.Pattern != "" {
+			return fmt.Errorf("only one pattern stage is supported")
+		}
+		pattern, err := unquoteStage(name, arg)
+		if err != nil {
+			return err
+		}
+		p.Pattern = pattern
+	case "line_format":
+		format, err := unquoteStage(name, arg)
+		if err != nil {
+			return err
+		}
+		p.LineFormat = format
+	case "json", "logfmt", "regexp", "unpack", "unwrap", "label_format", "drop", "keep", "decolorize":
+		return fmt.Errorf("stage '%s' is not supported", name)
+	default:
+		if p.Pattern == "" {
+			return fmt.Errorf("label filter '%s' before the pattern stage", strings.TrimSpace(stage))
+		}
+		if _, err := ParseWhere(stage); err != nil {
+			return err
+		}
+		p.LabelFilters = append(p.LabelFilters, strings.TrimSpace(stage))
+	}
+	return nil
+}
+
+// addLineFilters adds a sequence of line filters, such as |= "a" != "b".
+func (p *LogQLPipeline) addLineFilters(s string) error {
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if len(s) < 2 {
+			return fmt.Errorf("unexpected '%s'", s)
+		}
+		op := s[:2]
+		switch op {
+		case OpContains, OpNotContains, OpRegex, OpNotRegex:
+		default:
+			return fmt.Errorf("expected a line filter, got '%s'", s)
+		}
+		s = strings.TrimSpace(s[2:])
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return fmt.Errorf("expected a string after %s, got '%s'", op, s)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return fmt.Errorf("invalid string %s: %w", quoted, err)
+		}
+		if _, err := NewLineFilter(op, value); err != nil {
+			return err
+		}
+		p.LineFilters = append(p.LineFilters, LogQLLineFilter{Op: op, Value: value})
+		s = s[len(quoted):]
+	}
+	return nil
+}
+
+// selectorEnd returns the offset of the } closing the stream selector at the
+// start of s, skipping the } of its strings.
+func selectorEnd(s string) (int, error) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '`':
+			quoted, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return 0, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			i += len(quoted) - 1
+		case '}':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated stream selector '%s'", s)
+}
+
+func unquoteStage(name, arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	value, err := strconv.Unquote(arg)
+	if err != nil {
+		return "", fmt.Errorf("expected a string after %s, got '%s'", name, arg)
+	}
+	return value, nil
+}
+
+// splitPipeline splits a pipeline at the | outside of strings.
+func splitPipeline(s string) ([]string, error) {
+	var stages []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '`':
+			quoted, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			i += len(quoted) - 1
+		case '|':
+			stages = append(stages, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(stages, s[start:]), nil
+}
+
+// lineFormatFuncs are the template functions of line_format, a subset of
+// those of LogQL.
+var lineFormatFuncs = template.FuncMap{
+	"ToLower":    strings.ToLower,
+	"ToUpper":    strings.ToUpper,
+	"Replace":    strings.Replace,
+	"Trim":       strings.Trim,
+	"TrimLeft":   strings.TrimLeft,
+	"TrimRight":  strings.TrimRight,
+	"TrimPrefix": strings.TrimPrefix,
+	"TrimSuffix": strings.TrimSuffix,
+	"TrimSpace":  strings.TrimSpace,
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// LineFormatReplacer is a MultiReplacer formatting the matched lines with a
+// text/template executed on the captures, like the line_format stage of LogQL.
+type LineFormatReplacer struct {
+	*MultiReplacer
+	tmpl *template.Template
+}
+
+// NewLineFormatReplacer formats the lines matched by m with the template,
+// e.g. "{{.ip}} {{.status | ToUpper}}". Missing captures are empty.
+func NewLineFormatReplacer(m *MultiReplacer, format string) (*LineFormatReplacer, error) {
+	tmpl, err := template.New("line_format").Funcs(lineFormatFuncs).Option("missingkey=zero").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid line format '%s': %w", format, err)
+	}
+	return &LineFormatReplacer{MultiReplacer: m, tmpl: tmpl}, nil
+}
+
+// Replace formats the line, which is left unchanged if the template fails.
+func (r *LineFormatReplacer) Replace(line []byte) []byte {
+	return r.format(r.Captures(line), line)
+}
+
+// ReplacePattern formats the line with the captures of the pattern i.
+func (r *LineFormatReplacer) ReplacePattern(i int, line []byte) []byte {
+	return r.format(r.PatternCaptures(i, line), line)
+}
+
+func (r *LineFormatReplacer) format(c Captures, line []byte) []byte {
+	data := make(map[string]string, len(c.Names))
+	for i, name := range c.Names {
+		// Like Captures.Get, names without a value are missing.
+		if i < len(c.Values) {
+			data[name] = string(c.Values[i])
+		}
+	}
+	var b bytes.Buffer
+	if err := r.tmpl.Execute(&b, data); err != nil {
+		return line
+	}
+	return b.Bytes()
+}
+
+// Clone returns a LineFormatReplacer with the same patterns and its own match state.
+func (r *LineFormatReplacer) Clone() LineReplacer {
+	return &LineFormatReplacer{MultiReplacer: r.MultiReplacer.Clone().(*MultiReplacer), tmpl: r.tmpl}
+}
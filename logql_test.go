@@ -0,0 +1,164 @@
+package patt_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+func TestParseLogQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		logql   string
+		want    *patt.LogQLPipeline
+		wantErr string
+	}{
+		{
+			name:  "all stages",
+			logql: `|= "error" != "/health" | pattern "<ip> - <_> <status>" | status >= 500 | line_format "{{.ip}}"`,
+			want: &patt.LogQLPipeline{
+				LineFilters:  []patt.LogQLLineFilter{{Op: "|=", Value: "error"}, {Op: "!=", Value: "/health"}},
+				Pattern:      "<ip> - <_> <status>",
+				LabelFilters: []string{"status >= 500"},
+				LineFormat:   "{{.ip}}",
+			},
+		},
+		{
+			name:  "stream selector and regex filters",
+			logql: "{app=\"api|web\"} |~ `5\\d\\d` !~ \"GET|HEAD\"",
+			want: &patt.LogQLPipeline{
+				LineFilters: []patt.LogQLLineFilter{{Op: "|~", Value: `5\d\d`}, {Op: "!~", Value: "GET|HEAD"}},
+			},
+		},
+		{
+			name:  "line filter after pattern",
+			logql: "| pattern `<a> <b>` |= \"x|y\" | a = \"1\", b =~ \"2|3\"",
+			want: &patt.LogQLPipeline{
+				LineFilters:  []patt.LogQLLineFilter{{Op: "|=", Value: "x|y"}},
+				Pattern:      "<a> <b>",
+				LabelFilters: []string{`a = "1", b =~ "2|3"`},
+			},
+		},
+		{
+			name:  "stream selector with braces in strings",
+			logql: `{app="a}b", env=~"x{1}"} |= "x"`,
+			want:  &patt.LogQLPipeline{LineFilters: []patt.LogQLLineFilter{{Op: "|=", Value: "x"}}},
+		},
+		{name: "label filter before pattern", logql: `| status >= 500`, wantErr: "before the pattern stage"},
+		{name: "unsupported stage", logql: `| json`, wantErr: "stage 'json' is not supported"},
+		{name: "two patterns", logql: `| pattern "<a>" | pattern "<b>"`, wantErr: "only one pattern stage"},
+		{name: "stage after line_format", logql: `| pattern "<a>" | line_format "{{.a}}" |= "x"`, wantErr: "after line_format"},
+		{name: "unquoted pattern", logql: `| pattern <a>`, wantErr: "expected a string after pattern"},
+		{name: "invalid line filter", logql: `|= error`, wantErr: "expected a string after |="},
+		{name: "invalid regex", logql: `|~ "("`, wantErr: "invalid regular expression"},
+		{name: "invalid label filter", logql: `| pattern "<a>" | a >`, wantErr: "expected a value"},
+		{name: "unterminated string", logql: `|= "error`, wantErr: "unterminated string"},
+		{name: "unterminated stream selector", logql: `{app="api"`, wantErr: "unterminated stream selector"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patt.ParseLogQL(tt.logql)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseLogQL() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLogQL() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLogQL() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineFormatReplacer(t *testing.T) {
+	m, err := patt.NewMultiFilter([]string{"<ip> <method> <status>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := patt.NewLineFormatReplacer(m, `{{.method | ToLower}} {{.ip}} {{.missing | default "-"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []byte("10.0.0.1 GET 500")
+	if !r.Match(line) {
+		t.Fatalf("Match(%q) = false", line)
+	}
+	if got, want := string(r.Clone().(patt.LineReplacer).Replace(line)), "get 10.0.0.1 -"; got != want {
+		t.Errorf("Replace() = %q, want %q", got, want)
+	}
+
+	if _, err := patt.NewLineFormatReplacer(m, "{{.ip"); err == nil {
+		t.Error("NewLineFormatReplacer() should fail for an invalid template")
+	}
+}
+
+func TestRunCLI_LogQL(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "access.log")
+	writeFile(t, name, `10.0.0.1 - - [04/Dec/2005:04:47:44 +0000] "GET /api HTTP/1.1" 500 1234
+10.0.0.2 - - [04/Dec/2005:04:47:45 +0000] "GET /health HTTP/1.1" 500 12
+10.0.0.3 - - [04/Dec/2005:04:47:46 +0000] "POST /api HTTP/1.1" 200 2KB
+10.0.0.4 - - [04/Dec/2005:04:47:47 +0000] "POST /api HTTP/1.1" 503 2KB
+`)
+	logql := `{job="nginx"} != "/health" | pattern ` + "`<ip> - - [<_>] \"<method> <path> <_>\" <status> <size>`" +
+		` | status >= 500 | size > 1KB | line_format "{{.ip}} {{.method | ToLower}} {{.status}}"`
+	args := []string{"patt", "--logql", logql, name}
+	stdout := &bytes.Buffer{}
+
+	err := patt.RunCLI(context.Background(), args, strings.NewReader(""), stdout, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("RunCLI() error = %v", err)
+	}
+	if want := "10.0.0.1 get 500\n10.0.0.4 post 503\n"; stdout.String() != want {
+		t.Errorf("expected output %q, got %q", want, stdout.String())
+	}
+}
+
+func TestRunCLI_LogQLUnmatchedPattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		logql    string
+		expected string
+	}{
+		{
+			name:     "lines not matched by the pattern are kept",
+			logql:    `|= "error" | pattern "error a=<a>"`,
+			expected: "error a=1\nerror other\n",
+		},
+		{
+			name:     "without captures for line_format",
+			logql:    `|= "error" | pattern "error a=<a>" | line_format "[{{.a}}]"`,
+			expected: "[1]\n[]\n",
+		},
+		{
+			name:     "label filters drop them",
+			logql:    `|= "error" | pattern "error a=<a>" | a = "1"`,
+			expected: "error a=1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "input.log")
+			writeFile(t, name, "error a=1\nerror other\ninfo a=2\n")
+			stdout := &bytes.Buffer{}
+
+			err := patt.RunCLI(context.Background(), []string{"patt", "--logql", tt.logql, name}, strings.NewReader(""), stdout, &bytes.Buffer{})
+			if err != nil {
+				t.Fatalf("RunCLI() error = %v", err)
+			}
+			if stdout.String() != tt.expected {
+				t.Errorf("expected output %q, got %q", tt.expected, stdout.String())
+			}
+		})
+	}
+}
@@ -1,8 +1,14 @@
 package patt
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // CLIParams holds the command-line parameters.
@@ -11,14 +17,122 @@ type CLIParams struct {
 	ReplaceTemplate string
 	InputFiles      []string
 	Keep            bool
+	NoDecompress    bool
 	CPUProfile      string
+
+	// PatternsFiles are read for more search patterns and a template, see ReadPatternsFile.
+	PatternsFiles []string
+	// Use is the name of search patterns and a template of the pattern library, see ReadPatternLibrary.
+	Use string
+
+	// Jobs is the number of files processed concurrently, Unordered writes
+	// their output as soon as it is available instead of in input order.
+	Jobs      int
+	Unordered bool
+	// FailFast stops at the first input file that cannot be processed.
+	FailFast bool
+	// Split processes a single input file in chunks of about ChunkSize bytes concurrently.
+	Split     bool
+	ChunkSize int64
+
+	// MaxLineLength is the maximum length of a line in bytes, LongLines
+	// the policy for longer lines (fail, truncate or skip).
+	MaxLineLength int
+	LongLines     string
+
+	// Stats reports the number of lines matched by each pattern to stderr.
+	Stats bool
+	// Reorder tries the patterns by decreasing number of matches, when the
+	// pattern matching a line does not matter.
+	Reorder bool
+	// And are patterns that matching lines must also match, and Not patterns
+	// they must not match. The template may use the captures of And.
+	And []string
+	Not []string
+	// Contains, NotContains, Regex and NotRegex are the line filters checked
+	// before the patterns, like the |=, !=, |~ and !~ filters of LogQL.
+	Contains    []string
+	NotContains []string
+	Regex       []string
+	NotRegex    []string
+	// Where is a condition on the captures of the matching lines.
+	Where string
+	// LogQL is a LogQL pipeline, setting the search pattern, the line filters,
+	// Where and LineFormat. The positional arguments are then input files.
+	LogQL string
+	// LineFormat is a text/template formatting the captures, from LogQL.
+	LineFormat string
+	// AllMatches outputs a line for each matching pattern, prefixed by its
+	// index, instead of for the first one only.
+	AllMatches bool
+
+	// Coverage reports the lines matched by each pattern and by none to
+	// stderr, or to CoverageFile.
+	Coverage     bool
+	CoverageFile string
+
+	// Explain writes why the first Explain lines that do not match do not match.
+	Explain int
+
+	// Follow keeps reading the input file as it grows, like tail -F.
+	Follow bool
+	// FromStart, when following, reads the input file from the beginning
+	// instead of from its end.
+	FromStart bool
+	// ReportInterval, when following, reports aggregations periodically.
+	ReportInterval time.Duration
+
+	// Walk configures how directories in InputFiles are walked.
+	Walk WalkOptions
+
+	// Histogram is the name of a timestamp capture to count matches by.
+	Histogram  string
+	TimeLayout string
+	Bucket     time.Duration
+	Sparkline  bool
+
+	// Top is the name of a capture whose TopK most frequent values are reported.
+	Top  string
+	TopK int
+
+	// Distinct is the name of a capture whose unique values are printed,
+	// keeping at most DistinctLimit of them.
+	Distinct      string
+	DistinctLimit int
+	// Cardinality is the name of a capture whose number of unique values is estimated.
+	Cardinality string
+}
+
+// cliHelp describes patt and its subcommands in the help text.
+const cliHelp = `Search lines matching Loki-style patterns, and replace or aggregate them.
+
+Subcommands:
+  patt formats [name...]           list the bundled log formats
+  patt detect [file]               detect the format of a log
+  patt learn [file]                learn a pattern from example lines
+  patt cluster [file...]           cluster lines into templates
+  patt explain pattern [line...]   explain why lines do not match a pattern
+
+A first argument named like a subcommand runs it. To search for such a word,
+start with --, as in "patt -- explain -- file.log".`
+
+// HelpError is returned by ParseCLIParams when help is requested, with the
+// usage of patt.
+type HelpError struct {
+	Usage string
+}
+
+func (e *HelpError) Error() string {
+	return "help requested"
 }
 
 // ParseCLIParams parses flags + positional args
 //
 //
 //	patt [flags] search_pattern [[more_search ...] replace_pattern]
-//	     [-- file1 [file2 ...]]
+//	     [-- file_or_dir1 [file_or_dir2 ...]]
+//	patt [flags] (--patterns-file file | --use name) [[more_search ...] replace_pattern]
+//	     [-- file_or_dir1 [file_or_dir2 ...]]
 //
 // Flags:   -k / --keep  (bool)
 func ParseCLIParams(argsWithFlags []string) (CLIParams, error) {
@@ -26,6 +140,7 @@ func ParseCLIParams(argsWithFlags []string) (CLIParams, error) {
 
 	cmd := &cobra.Command{
 		Use:  "patt [flags] search_pattern [[search_pattern ...] replace_template] [-- input_files...]",
+		Long: cliHelp,
 		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			doubleDashPos := cmd.ArgsLenAtDash()
@@ -37,10 +152,65 @@ func ParseCLIParams(argsWithFlags []string) (CLIParams, error) {
 				out.InputFiles = args[doubleDashPos:]
 			}
 
-			switch len(patterns) {
-			case 0:
+			for _, glob := range append(out.Walk.Include, out.Walk.Exclude...) {
+				if _, err := filepath.Match(glob, ""); err != nil {
+					return fmt.Errorf("invalid glob '%s': %w", glob, err)
+				}
+			}
+
+			if out.LongLines != "" {
+				if _, err := ParseLongLinePolicy(out.LongLines); err != nil {
+					return err
+				}
+			}
+			if out.Follow && len(out.InputFiles) != 1 {
+				return fmt.Errorf("follow mode requires a single input file")
+			}
+			if out.CoverageFile != "" {
+				out.Coverage = true
+			}
+			if out.Where != "" {
+				if _, err := ParseWhere(out.Where); err != nil {
+					return err
+				}
+			}
+			if _, err := out.LineFilters(); err != nil {
+				return err
+			}
+			if out.AllMatches && len(out.And)+len(out.Not) > 0 {
+				return fmt.Errorf("--all-matches cannot be combined with --and or --not")
+			}
+			if out.Follow && out.Split {
+				return fmt.Errorf("cannot split a file in follow mode")
+			}
+			if out.FromStart && !out.Follow {
+				return fmt.Errorf("--from-start requires --follow")
+			}
+			if out.ReportInterval != 0 && !out.Follow {
+				return fmt.Errorf("--report-interval requires --follow")
+			}
+
+			switch {
+			case out.LogQL != "":
+				if len(out.PatternsFiles) > 0 || out.Use != "" || len(out.And)+len(out.Not) > 0 {
+					return fmt.Errorf("--logql cannot be combined with --patterns-file, --use, --and or --not")
+				}
+				if out.Reorder || out.AllMatches {
+					// The pattern stage must be tried before the <_> keeping the other lines.
+					return fmt.Errorf("--logql cannot be combined with --reorder or --all-matches")
+				}
+				// The pipeline replaces the patterns, the arguments are files.
+				out.InputFiles = append(patterns, out.InputFiles...)
+				return out.useLogQL()
+			case len(out.PatternsFiles) > 0 || out.Use != "":
+				// The search patterns are in files, the last argument is the template.
+				if len(patterns) > 0 {
+					out.SearchPatterns = patterns[:len(patterns)-1]
+					out.ReplaceTemplate = patterns[len(patterns)-1]
+				}
+			case len(patterns) == 0:
 				return fmt.Errorf("at least one search pattern is required")
-			case 1:
+			case len(patterns) == 1:
 				out.SearchPatterns = patterns
 			default:
 				out.SearchPatterns = patterns[:len(patterns)-1]
@@ -50,13 +220,56 @@ func ParseCLIParams(argsWithFlags []string) (CLIParams, error) {
 		},
 	}
 
+	cmd.Flags().StringArrayVar(&out.PatternsFiles, "patterns-file", nil, "read search patterns from the file, one per line, and a template from a line starting with => (repeatable)")
+	cmd.Flags().StringVar(&out.Use, "use", "", "use the named search patterns and template of .patt/patterns, ~/.config/patt/patterns or of a bundled format (see patt formats)")
 	cmd.Flags().BoolVarP(&out.Keep, "keep", "k", false, "print non‑matching lines")
+	cmd.Flags().BoolVar(&out.Stats, "stats", false, "report the lines matched by each pattern and the patterns tried per line to stderr")
+	cmd.Flags().BoolVar(&out.Reorder, "reorder", false, "try the most matching patterns first, the first matching pattern of a line may then change")
+	cmd.Flags().StringArrayVar(&out.Contains, "contains", nil, "only try the patterns on lines containing the string (repeatable)")
+	cmd.Flags().StringArrayVar(&out.NotContains, "not-contains", nil, "only try the patterns on lines not containing the string (repeatable)")
+	cmd.Flags().StringArrayVar(&out.Regex, "regex", nil, "only try the patterns on lines matching the regular expression (repeatable)")
+	cmd.Flags().StringArrayVar(&out.NotRegex, "not-regex", nil, "only try the patterns on lines not matching the regular expression (repeatable)")
+	cmd.Flags().StringVar(&out.LogQL, "logql", "", "run a LogQL pipeline of line filters, pattern, label filters and line_format stages, e.g. '|= \"error\" | pattern \"<ip> <_>\" | line_format \"{{.ip}}\"'")
+	cmd.Flags().StringVar(&out.Where, "where", "", "only keep matching lines whose captures satisfy the condition, e.g. 'status >= 500 and latency > 250ms'")
+	cmd.Flags().StringArrayVar(&out.And, "and", nil, "only keep lines also matching the pattern, whose captures can be used in the template (repeatable)")
+	cmd.Flags().StringArrayVar(&out.Not, "not", nil, "only keep lines not matching the pattern (repeatable)")
+	cmd.Flags().BoolVar(&out.AllMatches, "all-matches", false, "output a line prefixed by the pattern index and a tab for each pattern matching a line, not only for the first one")
+	cmd.Flags().BoolVar(&out.Coverage, "coverage", false, "report the lines matched by each pattern and a summary of the unmatched lines to stderr")
+	cmd.Flags().StringVar(&out.CoverageFile, "coverage-file", "", "write the --coverage report to the file instead of stderr")
+	cmd.Flags().IntVar(&out.Explain, "explain", 0, "write why the first n non-matching lines do not match to stderr")
+	cmd.Flags().BoolVarP(&out.Follow, "follow", "f", false, "keep reading the input file as it grows, handling truncation and rotation")
+	cmd.Flags().BoolVar(&out.FromStart, "from-start", false, "when following, read the input file from the beginning instead of only the new lines")
+	cmd.Flags().DurationVar(&out.ReportInterval, "report-interval", 0, "when following, report aggregations at this interval")
+	cmd.Flags().IntVar(&out.MaxLineLength, "max-line-length", 0, "maximum line length in bytes (default 16MiB)")
+	cmd.Flags().StringVar(&out.LongLines, "long-lines", "", "what to do with lines longer than --max-line-length: fail, truncate or skip (default fail)")
+	cmd.Flags().IntVarP(&out.Jobs, "jobs", "j", 0, "number of files processed concurrently (default 4)")
+	cmd.Flags().BoolVar(&out.Unordered, "unordered", false, "write the output of each file as soon as it is ready, not in input order")
+	cmd.Flags().BoolVar(&out.FailFast, "fail-fast", false, "stop at the first input file that cannot be processed")
+	cmd.Flags().BoolVar(&out.Split, "split", false, "process a single input file concurrently, split in chunks at line boundaries")
+	cmd.Flags().Int64Var(&out.ChunkSize, "chunk-size", 0, "size in bytes of the chunks processed by --split (default 64MiB)")
+	cmd.Flags().BoolVar(&out.NoDecompress, "no-decompress", false, "do not decompress gzip, bzip2 and zlib input files")
+	cmd.Flags().StringArrayVar(&out.Walk.Include, "include", nil, "only read files matching the glob when walking directories (repeatable)")
+	cmd.Flags().StringArrayVar(&out.Walk.Exclude, "exclude", nil, "skip files and directories matching the glob when walking directories (repeatable)")
+	cmd.Flags().BoolVar(&out.Walk.Hidden, "hidden", false, "read hidden files and directories when walking directories")
+	cmd.Flags().BoolVar(&out.Walk.FollowSymlinks, "follow-symlinks", false, "follow symbolic links when walking directories")
+	cmd.Flags().StringVar(&out.Histogram, "histogram", "", "count matches per time bucket of the given timestamp capture")
+	cmd.Flags().StringVar(&out.TimeLayout, "time-layout", "", "Go time layout or preset (auto, apache, clf, rfc3339, datetime, syslog, unix) of the histogram capture")
+	cmd.Flags().DurationVar(&out.Bucket, "bucket", 0, "histogram bucket width (default 1m)")
+	cmd.Flags().BoolVar(&out.Sparkline, "sparkline", false, "print the histogram as a sparkline")
+	cmd.Flags().StringVar(&out.Top, "top", "", "report the most frequent values of the given capture")
+	cmd.Flags().IntVar(&out.TopK, "top-k", 0, "number of values reported by --top (default 10)")
+	cmd.Flags().StringVar(&out.Distinct, "distinct", "", "print the unique values of the given capture")
+	cmd.Flags().IntVar(&out.DistinctLimit, "distinct-limit", 0, "maximum number of values kept by --distinct (default 100000)")
+	cmd.Flags().StringVar(&out.Cardinality, "cardinality", "", "estimate the number of unique values of the given capture")
 	cmd.Flags().StringVar(&out.CPUProfile, "cpu-profile", "", "write cpu profile to file")
 	if err := cmd.Flags().MarkHidden("cpu-profile"); err != nil {
 		return out, err
 	}
 
 	if err := cmd.ParseFlags(argsWithFlags); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return out, &HelpError{Usage: cmd.Long + "\n\n" + cmd.UsageString()}
+		}
 		return out, err
 	}
 	if err := cmd.RunE(cmd, cmd.Flags().Args()); err != nil {
@@ -64,3 +277,75 @@ func ParseCLIParams(argsWithFlags []string) (CLIParams, error) {
 	}
 	return out, nil
 }
+
+// useLogQL sets the search pattern, line filters, where condition and line
+// format of the LogQL pipeline.
+func (p *CLIParams) useLogQL() error {
+	pipeline, err := ParseLogQL(p.LogQL)
+	if err != nil {
+		return err
+	}
+	p.SearchPatterns = []string{"<_>"}
+	if pipeline.Pattern != "" {
+		// Like Loki, lines the pattern does not match are kept without
+		// captures, only label filters drop them.
+		p.SearchPatterns = []string{pipeline.Pattern, "<_>"}
+	}
+	for _, f := range pipeline.LineFilters {
+		switch f.Op {
+		case OpContains:
+			p.Contains = append(p.Contains, f.Value)
+		case OpNotContains:
+			p.NotContains = append(p.NotContains, f.Value)
+		case OpRegex:
+			p.Regex = append(p.Regex, f.Value)
+		case OpNotRegex:
+			p.NotRegex = append(p.NotRegex, f.Value)
+		}
+	}
+	conditions := pipeline.LabelFilters
+	if p.Where != "" {
+		conditions = append([]string{p.Where}, conditions...)
+	}
+	if len(conditions) == 1 {
+		p.Where = conditions[0]
+	} else if len(conditions) > 1 {
+		p.Where = "(" + strings.Join(conditions, ") and (") + ")"
+	}
+	p.LineFormat = pipeline.LineFormat
+	return nil
+}
+
+// capturePatterns returns the search patterns that must define the captures
+// of --where and of the aggregations. With --logql, only the pattern stage
+// does, the lines it does not match having no captures.
+func (p CLIParams) capturePatterns() []string {
+	if p.LogQL != "" {
+		return p.SearchPatterns[:1]
+	}
+	return p.SearchPatterns
+}
+
+// LineFilters returns the line filters of the --contains, --not-contains,
+// --regex and --not-regex flags, the cheapest first.
+func (p CLIParams) LineFilters() ([]LinesMatcher, error) {
+	var filters []LinesMatcher
+	for _, f := range []struct {
+		op     string
+		values []string
+	}{
+		{OpContains, p.Contains},
+		{OpNotContains, p.NotContains},
+		{OpRegex, p.Regex},
+		{OpNotRegex, p.NotRegex},
+	} {
+		for _, value := range f.values {
+			filter, err := NewLineFilter(f.op, value)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+	}
+	return filters, nil
+}
@@ -3,6 +3,7 @@ package patt
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParseCLIParams_NoErrors(t *testing.T) {
@@ -89,6 +90,176 @@ func TestParseCLIParams_NoErrors(t *testing.T) {
 				CPUProfile:      "cpu.pprof",
 			},
 		},
+		{
+			name: "histogram flags",
+			args: []string{"--histogram", "ts", "--time-layout", "apache", "--bucket", "5m", "--sparkline", "pattern"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				Histogram:      "ts",
+				TimeLayout:     "apache",
+				Bucket:         5 * time.Minute,
+				Sparkline:      true,
+			},
+		},
+		{
+			name: "top flags",
+			args: []string{"--top", "ip", "--top-k", "20", "pattern"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				Top:            "ip",
+				TopK:           20,
+			},
+		},
+		{
+			name: "distinct and cardinality flags",
+			args: []string{"--distinct", "user", "--distinct-limit", "5", "--cardinality", "ip", "pattern"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				Distinct:       "user",
+				DistinctLimit:  5,
+				Cardinality:    "ip",
+			},
+		},
+		{
+			name: "no decompress flag",
+			args: []string{"--no-decompress", "pattern", "--", "input.gz"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				InputFiles:     []string{"input.gz"},
+				NoDecompress:   true,
+			},
+		},
+		{
+			name: "directory walking flags",
+			args: []string{"--include", "*.log", "--include", "*.gz", "--exclude", "vendor", "--hidden", "--follow-symlinks", "pattern", "--", "logs"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				InputFiles:     []string{"logs"},
+				Walk: WalkOptions{
+					Include:        []string{"*.log", "*.gz"},
+					Exclude:        []string{"vendor"},
+					Hidden:         true,
+					FollowSymlinks: true,
+				},
+			},
+		},
+		{
+			name: "follow flags",
+			args: []string{"-f", "--from-start", "--report-interval", "10s", "pattern", "--", "input.txt"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				InputFiles:     []string{"input.txt"},
+				Follow:         true,
+				FromStart:      true,
+				ReportInterval: 10 * time.Second,
+			},
+		},
+		{
+			name: "long lines flags",
+			args: []string{"--max-line-length", "1024", "--long-lines", "skip", "pattern"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				MaxLineLength:  1024,
+				LongLines:      "skip",
+			},
+		},
+		{
+			name: "concurrency flags",
+			args: []string{"-j", "8", "--unordered", "pattern", "--", "input.txt", "input2.txt"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				InputFiles:     []string{"input.txt", "input2.txt"},
+				Jobs:           8,
+				Unordered:      true,
+			},
+		},
+		{
+			name: "split flags",
+			args: []string{"--split", "--chunk-size", "1048576", "pattern", "--", "input.txt"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				InputFiles:     []string{"input.txt"},
+				Split:          true,
+				ChunkSize:      1048576,
+			},
+		},
+		{
+			name: "patterns file only",
+			args: []string{"--patterns-file", "patterns.txt", "--", "input.txt"},
+			want: CLIParams{
+				PatternsFiles: []string{"patterns.txt"},
+				InputFiles:    []string{"input.txt"},
+			},
+		},
+		{
+			name: "patterns files with template and more patterns",
+			args: []string{"--patterns-file", "a.txt", "--patterns-file", "b.txt", "pattern", "replacement"},
+			want: CLIParams{
+				PatternsFiles:   []string{"a.txt", "b.txt"},
+				SearchPatterns:  []string{"pattern"},
+				ReplaceTemplate: "replacement",
+			},
+		},
+		{
+			name: "fail fast flag",
+			args: []string{"--fail-fast", "pattern", "--", "input.txt", "input2.txt"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				InputFiles:     []string{"input.txt", "input2.txt"},
+				FailFast:       true,
+			},
+		},
+		{
+			name: "and and not patterns",
+			args: []string{"--and", "<_> slot <slot>", "--not", "<_> debug <_>", "--not", "<_> trace <_>", "pattern", "replacement"},
+			want: CLIParams{
+				SearchPatterns:  []string{"pattern"},
+				ReplaceTemplate: "replacement",
+				And:             []string{"<_> slot <slot>"},
+				Not:             []string{"<_> debug <_>", "<_> trace <_>"},
+			},
+		},
+		{
+			name: "where condition",
+			args: []string{"--where", "status >= 500", "pattern"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				Where:          "status >= 500",
+			},
+		},
+		{
+			name: "logql pipeline",
+			args: []string{"--where", "size > 1KB", "--logql", `|= "error" | pattern "<ip> <status> <size>" | status >= 500 | line_format "{{.ip}}"`, "input.txt"},
+			want: CLIParams{
+				SearchPatterns: []string{"<ip> <status> <size>", "<_>"},
+				InputFiles:     []string{"input.txt"},
+				Contains:       []string{"error"},
+				Where:          "(size > 1KB) and (status >= 500)",
+				LogQL:          `|= "error" | pattern "<ip> <status> <size>" | status >= 500 | line_format "{{.ip}}"`,
+				LineFormat:     "{{.ip}}",
+			},
+		},
+		{
+			name: "logql line filters only",
+			args: []string{"--logql", `!~ "debug"`, "--", "input.txt"},
+			want: CLIParams{
+				SearchPatterns: []string{"<_>"},
+				InputFiles:     []string{"input.txt"},
+				NotRegex:       []string{"debug"},
+				LogQL:          `!~ "debug"`,
+			},
+		},
+		{
+			name: "line filters",
+			args: []string{"--contains", "error", "--not-contains", "debug", "--regex", "5..", "--not-regex", "/health", "pattern"},
+			want: CLIParams{
+				SearchPatterns: []string{"pattern"},
+				Contains:       []string{"error"},
+				NotContains:    []string{"debug"},
+				Regex:          []string{"5.."},
+				NotRegex:       []string{"/health"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -114,6 +285,54 @@ func TestParseCLIParams_WithErrors(t *testing.T) {
 			name: "missing pattern",
 			args: []string{},
 		},
+		{
+			name: "invalid glob",
+			args: []string{"--include", "[", "pattern"},
+		},
+		{
+			name: "follow without input file",
+			args: []string{"-f", "pattern"},
+		},
+		{
+			name: "follow multiple input files",
+			args: []string{"-f", "pattern", "--", "input.txt", "input2.txt"},
+		},
+		{
+			name: "unknown long lines policy",
+			args: []string{"--long-lines", "wrap", "pattern"},
+		},
+		{
+			name: "report interval without follow",
+			args: []string{"--report-interval", "10s", "--top", "ip", "pattern", "--", "input.txt"},
+		},
+		{
+			name: "from start without follow",
+			args: []string{"--from-start", "pattern", "--", "input.txt"},
+		},
+		{
+			name: "split in follow mode",
+			args: []string{"-f", "--split", "pattern", "--", "input.txt"},
+		},
+		{
+			name: "all matches with not patterns",
+			args: []string{"--all-matches", "--not", "<_> debug <_>", "pattern"},
+		},
+		{
+			name: "invalid where condition",
+			args: []string{"--where", "status >=", "pattern"},
+		},
+		{
+			name: "logql with patterns file",
+			args: []string{"--logql", `|= "error"`, "--patterns-file", "patterns.txt"},
+		},
+		{
+			name: "logql with reorder",
+			args: []string{"--logql", `| pattern "<a> <b>"`, "--reorder"},
+		},
+		{
+			name: "invalid logql pipeline",
+			args: []string{"--logql", `| json`},
+		},
 		{
 			name: "unknown flag",
 			args: []string{"pattern", "replacement", "--unknown-flag"},
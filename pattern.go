@@ -1,7 +1,12 @@
 package patt
 
 import (
+	"bytes"
+	"cmp"
 	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
 
 	"patt/pattern"
 )
@@ -19,19 +24,59 @@ type LinesMatcher interface {
 }
 
 type PatternMatcher struct {
-	filter pattern.Matcher
+	filter  pattern.Matcher
+	pattern string
 }
 
 func (m PatternMatcher) Match(b []byte) bool {
 	return m.filter.Test(b)
 }
 
+// RequiredLiteral returns a literal contained in all the lines matched, if any.
+func (m PatternMatcher) RequiredLiteral() []byte {
+	return m.filter.LongestLiteral()
+}
+
+// Suffix returns the literal ending all the lines matched, if any.
+func (m PatternMatcher) Suffix() []byte {
+	return m.filter.Suffix()
+}
+
+func (m PatternMatcher) Captures(b []byte) Captures {
+	return Captures{Names: m.filter.Names(), Values: m.filter.Matches(b)}
+}
+
+// Explain returns why b does not match the pattern, or nil if it matches.
+func (m PatternMatcher) Explain(b []byte) []Explanation {
+	if mismatch := m.filter.Explain(b); mismatch != nil {
+		return []Explanation{{Pattern: m.pattern, Mismatch: *mismatch}}
+	}
+	return nil
+}
+
+// Captures holds the named values extracted from a matched line.
+// Values[i] is the value of the capture Names[i].
+type Captures struct {
+	Names  []string
+	Values [][]byte
+}
+
+// Get returns the value of the capture with the given name.
+func (c Captures) Get(name string) ([]byte, bool) {
+	for i, n := range c.Names {
+		if n == name && i < len(c.Values) {
+			return c.Values[i], true
+		}
+	}
+	return nil, false
+}
+
 func NewFilter(stringPattern string) (LineReplacer, error) {
 	filter, err := pattern.ParseLineFilter([]byte(stringPattern))
 	if err != nil {
 		return nil, err
 	}
-	matcher := PatternMatcher{filter: *filter}
+	matcher := PatternMatcher{filter: *filter, pattern: stringPattern}
 	replacer := matchFilter{PatternMatcher: &matcher}
 	return replacer, nil
 }
@@ -59,7 +104,7 @@ func NewReplacer(stringPattern, stringReplaceTemplate string) (*Replacer, error)
 		return nil, err
 	}
 	return &Replacer{
-		PatternMatcher: &PatternMatcher{filter: *filter},
+		PatternMatcher: &PatternMatcher{filter: *filter, pattern: stringPattern},
 		literals:       literals,
 		positions:      positions,
 	}, nil
@@ -84,9 +129,21 @@ func capturesPositions(sourceNames []string, replaceNames []string, literals [][
 	return positions, nil
 }
 
+// cloneReplacer returns a replacer that can be used concurrently with r. Replacers
+// keeping state between Match and Replace implement Clone() LineReplacer.
+func cloneReplacer(r LineReplacer) LineReplacer {
+	if c, ok := r.(interface{ Clone() LineReplacer }); ok {
+		return c.Clone()
+	}
+	return r
+}
+
 type LineReplacer interface {
 	LinesMatcher
 	Replace(b []byte) []byte
+	// Captures returns the named captures of b.
+	// Like Replace, it requires that Match(b) has previously returned true.
+	Captures(b []byte) Captures
 }
 type Replacer struct {
 	*PatternMatcher
@@ -108,8 +165,8 @@ func (r *Replacer) Replace(b []byte) []byte {
 	return result
 }
 
-func NewMultiReplacer(patterns []string, template string) (*MultiReplacer, error) {
-	replacers := make([]*Replacer, 0, len(patterns))
+func NewMultiReplacer(patterns []string, template string, opts ...MultiReplacerOption) (*MultiReplacer, error) {
+	replacers := make([]LineReplacer, 0, len(patterns))
 	for _, pat := range patterns {
 		r, err := NewReplacer(pat, template)
 		if err != nil {
@@ -117,10 +174,130 @@ func NewMultiReplacer(patterns []string, template string) (*MultiReplacer, error
 		}
 		replacers = append(replacers, r)
 	}
-	return &MultiReplacer{
+	return newMultiReplacer(patterns, replacers, opts), nil
+}
+
+// NewMultiFilter creates a MultiReplacer that matches any of the patterns and
+// leaves matching lines unchanged.
+func NewMultiFilter(patterns []string, opts ...MultiReplacerOption) (*MultiReplacer, error) {
+	filters := make([]LineReplacer, 0, len(patterns))
+	for _, pat := range patterns {
+		f, err := NewFilter(pat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create filter for pattern '%s': %w", pat, err)
+		}
+		filters = append(filters, f)
+	}
+	return newMultiReplacer(patterns, filters, opts), nil
+}
+
+// MultiReplacerOption configures optional behaviour of a MultiReplacer.
+type MultiReplacerOption func(*MultiReplacer)
+
+// WithAdaptiveOrder tries the patterns by decreasing number of matches
+// instead of in order, when the pattern matching a line does not matter.
+// Patterns that are provably disjoint are always tried adaptively.
+func WithAdaptiveOrder() MultiReplacerOption {
+	return func(m *MultiReplacer) {
+		m.adaptive = true
+	}
+}
+
+// WithoutPrefilter tries each pattern on every line, instead of only those
+// whose required literal is found by a single pass over the line.
+func WithoutPrefilter() MultiReplacerOption {
+	return func(m *MultiReplacer) {
+		m.noPrefilter = true
+	}
+}
+
+// WithAllPatterns tries all the patterns on each line, instead of stopping at
+// the first matching one. MatchedPatterns then returns all the matching patterns.
+func WithAllPatterns() MultiReplacerOption {
+	return func(m *MultiReplacer) {
+		m.all = true
+	}
+}
+
+// WithLineFilters only tries the patterns on the lines matched by all the
+// filters, such as those of NewLineFilter, which should be cheaper to check.
+func WithLineFilters(filters ...LinesMatcher) MultiReplacerOption {
+	return func(m *MultiReplacer) {
+		m.lineFilters = append(m.lineFilters, filters...)
+	}
+}
+
+// prefilterMinPatterns is the number of patterns from which a MultiReplacer
+// prefilters the patterns tried on a line.
+const prefilterMinPatterns = 4
+
+func newMultiReplacer(patterns []string, replacers []LineReplacer, opts []MultiReplacerOption) *MultiReplacer {
+	m := &MultiReplacer{
 		patterns:  patterns,
 		replacers: replacers,
-	}, nil
+		adaptive:  disjoint(replacers),
+		stats:     &multiStats{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.all {
+		// All the patterns are tried, in order.
+		m.adaptive = false
+	}
+	if !m.noPrefilter && len(replacers) >= prefilterMinPatterns {
+		m.buildPrefilter()
+	}
+	m.init()
+	return m
+}
+
+// buildPrefilter builds the automaton finding the required literals of the
+// replacers. Replacers without a required literal are tried on every line.
+func (m *MultiReplacer) buildPrefilter() {
+	var literals [][]byte
+	indexes := map[string]int{}
+	m.literalIx = make([]int, len(m.replacers))
+	for i, r := range m.replacers {
+		m.literalIx[i] = -1
+		rl, ok := r.(interface{ RequiredLiteral() []byte })
+		if !ok || len(rl.RequiredLiteral()) == 0 {
+			continue
+		}
+		literal := rl.RequiredLiteral()
+		ix, ok := indexes[string(literal)]
+		if !ok {
+			ix = len(literals)
+			indexes[string(literal)] = ix
+			literals = append(literals, literal)
+		}
+		m.literalIx[i] = ix
+	}
+	if len(literals) > 0 {
+		m.prefilter = newLiteralSet(literals)
+	}
+}
+
+// disjoint reports whether no line can match two of the replacers, because
+// they end with literals that are not suffixes of one another.
+func disjoint(replacers []LineReplacer) bool {
+	suffixes := make([][]byte, len(replacers))
+	for i, r := range replacers {
+		s, ok := r.(interface{ Suffix() []byte })
+		if !ok {
+			return false
+		}
+		suffixes[i] = s.Suffix()
+		if len(suffixes[i]) == 0 {
+			return false
+		}
+		for _, other := range suffixes[:i] {
+			if bytes.HasSuffix(suffixes[i], other) || bytes.HasSuffix(other, suffixes[i]) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 // MultiReplacer matches multiple patterns and applies a single replacement template.
@@ -130,21 +307,261 @@ func NewMultiReplacer(patterns []string, template string) (*MultiReplacer, error
 // If Replace is called without a prior successful Match, it will fail.
 type MultiReplacer struct {
 	patterns      []string
-	replacers     []*Replacer
+	replacers     []LineReplacer
 	lastMatchedIx int
+
+	// all tries all the patterns, matched being those matching the last line.
+	all     bool
+	matched []int
+
+	// order is the order in which the replacers are tried, sorted by
+	// decreasing number of matches every reorderInterval lines when adaptive.
+	adaptive bool
+	order    []int
+	counters *matchCounters
+	stats    *multiStats
+
+	// lineFilters must all match a line for the patterns to be tried.
+	lineFilters []LinesMatcher
+
+	// prefilter finds the required literals of a line, literalIx[i] being
+	// the index of the literal of replacer i, or -1 when it has none. Only
+	// the candidates of the found literals and the unfiltered replacers,
+	// without literal, are tried, both kept in order. rank[i] is the
+	// position of replacer i in order.
+	noPrefilter bool
+	prefilter   *literalSet
+	literalIx   []int
+	candidates  [][]int
+	unfiltered  []int
+	rank        []int
+	found       []bool
+	hits        []int32
+	tried       []int
+}
+
+// reorderInterval is the number of lines after which an adaptive
+// MultiReplacer sorts its patterns by number of matches.
+const reorderInterval = 4096
+
+// matchCounters are the counters of a MultiReplacer, only updated by the
+// goroutine using it.
+type matchCounters struct {
+	lines atomic.Int64
+	tries atomic.Int64
+	hits  []atomic.Int64
+}
+
+// multiStats collects the counters of a MultiReplacer and its clones.
+type multiStats struct {
+	mu       sync.Mutex
+	counters []*matchCounters
+}
+
+// MatchStats are the number of lines matched by each pattern of a MultiReplacer.
+type MatchStats struct {
+	Lines int64
+	// Tries is the number of patterns tried, over all the lines.
+	Tries int64
+	Hits  []int64
+}
+
+func (m *MultiReplacer) init() {
+	m.order = make([]int, len(m.replacers))
+	for i := range m.order {
+		m.order[i] = i
+	}
+	m.counters = &matchCounters{hits: make([]atomic.Int64, len(m.replacers))}
+	if m.prefilter != nil {
+		m.found = make([]bool, len(m.prefilter.outputs))
+		m.candidates = make([][]int, len(m.found))
+		m.unfiltered = nil
+		for _, i := range m.order {
+			if l := m.literalIx[i]; l >= 0 {
+				m.candidates[l] = append(m.candidates[l], i)
+			} else {
+				m.unfiltered = append(m.unfiltered, i)
+			}
+		}
+		m.rank = make([]int, len(m.order))
+		for pos, i := range m.order {
+			m.rank[i] = pos
+		}
+	}
+	m.stats.mu.Lock()
+	defer m.stats.mu.Unlock()
+	m.stats.counters = append(m.stats.counters, m.counters)
 }
 
 func (m *MultiReplacer) Match(line []byte) bool {
-	for i, r := range m.replacers {
-		if r.Match(line) {
+	lines := m.counters.lines.Add(1)
+	if m.adaptive && lines%reorderInterval == 0 {
+		m.reorder()
+	}
+	m.matched = m.matched[:0]
+	for _, f := range m.lineFilters {
+		if !f.Match(line) {
+			m.lastMatchedIx = -1
+			return false
+		}
+	}
+	tries := int64(0)
+	for _, i := range m.candidateOrder(line) {
+		tries++
+		if m.replacers[i].Match(line) {
+			if m.all {
+				m.matched = append(m.matched, i)
+				m.counters.hits[i].Add(1)
+				continue
+			}
 			m.lastMatchedIx = i
+			m.counters.tries.Add(tries)
+			m.counters.hits[i].Add(1)
 			return true
 		}
 	}
+	m.counters.tries.Add(tries)
+	if len(m.matched) > 0 {
+		m.lastMatchedIx = m.matched[0]
+		return true
+	}
 	m.lastMatchedIx = -1
 	return false
 }
 
+// candidateOrder returns the replacers to try on the line, in order: all of
+// them without prefilter, otherwise those whose literal the line contains
+// and those without literal.
+func (m *MultiReplacer) candidateOrder(line []byte) []int {
+	if m.prefilter == nil {
+		return m.order
+	}
+	m.hits = m.prefilter.find(line, m.found, m.hits[:0])
+	m.tried = append(m.tried[:0], m.unfiltered...)
+	for _, l := range m.hits {
+		m.found[l] = false
+		m.tried = append(m.tried, m.candidates[l]...)
+	}
+	if len(m.hits) > 1 || len(m.hits) == 1 && len(m.unfiltered) > 0 {
+		slices.SortFunc(m.tried, func(a, b int) int {
+			return cmp.Compare(m.rank[a], m.rank[b])
+		})
+	}
+	return m.tried
+}
+
+func (m *MultiReplacer) reorder() {
+	hits := m.counters.hits
+	slices.SortStableFunc(m.order, func(a, b int) int {
+		return cmp.Compare(hits[b].Load(), hits[a].Load())
+	})
+	if m.prefilter == nil {
+		return
+	}
+	for pos, i := range m.order {
+		m.rank[i] = pos
+	}
+	byRank := func(a, b int) int { return cmp.Compare(m.rank[a], m.rank[b]) }
+	for _, c := range m.candidates {
+		slices.SortFunc(c, byRank)
+	}
+	slices.SortFunc(m.unfiltered, byRank)
+}
+
+// MatchedPattern returns the index of the pattern that matched the last line
+// passed to Match, or -1 if none did.
+func (m *MultiReplacer) MatchedPattern() int {
+	return m.lastMatchedIx
+}
+
+// MatchedPatterns returns the indexes of the patterns that matched the last
+// line passed to Match: all of them with WithAllPatterns, the first one otherwise.
+// The slice is only valid until the next call to Match.
+func (m *MultiReplacer) MatchedPatterns() []int {
+	if !m.all {
+		if m.lastMatchedIx < 0 {
+			return nil
+		}
+		return []int{m.lastMatchedIx}
+	}
+	return m.matched
+}
+
+// ReplacePattern returns the replacement of the line by the pattern i, which
+// must be one of the MatchedPatterns of the line.
+func (m *MultiReplacer) ReplacePattern(i int, line []byte) []byte {
+	return m.replacers[i].Replace(line)
+}
+
+// PatternCaptures returns the captures of the line by the pattern i, which
+// must be one of the MatchedPatterns of the line.
+func (m *MultiReplacer) PatternCaptures(i int, line []byte) Captures {
+	return m.replacers[i].Captures(line)
+}
+
+// Stats returns the number of lines matched by each pattern, by m and its clones.
+func (m *MultiReplacer) Stats() MatchStats {
+	stats := MatchStats{Hits: make([]int64, len(m.replacers))}
+	m.stats.mu.Lock()
+	defer m.stats.mu.Unlock()
+	for _, c := range m.stats.counters {
+		stats.Lines += c.lines.Load()
+		stats.Tries += c.tries.Load()
+		for i := range c.hits {
+			stats.Hits[i] += c.hits[i].Load()
+		}
+	}
+	return stats
+}
+
+// Order returns the indexes of the patterns in the order they are tried.
+func (m *MultiReplacer) Order() []int {
+	return slices.Clone(m.order)
+}
+
 func (m *MultiReplacer) Replace(line []byte) []byte {
 	return m.replacers[m.lastMatchedIx].Replace(line)
-}
\ No newline at end of file
+}
+
+// Clone returns a MultiReplacer with the same patterns and its own match
+// state. Its counters are included in the Stats of m.
+func (m *MultiReplacer) Clone() LineReplacer {
+	c := &MultiReplacer{
+		patterns:    m.patterns,
+		replacers:   m.replacers,
+		adaptive:    m.adaptive,
+		all:         m.all,
+		lineFilters: m.lineFilters,
+		stats:       m.stats,
+		noPrefilter: m.noPrefilter,
+		prefilter:   m.prefilter,
+		literalIx:   m.literalIx,
+	}
+	c.init()
+	return c
+}
+
+func (m *MultiReplacer) Captures(line []byte) Captures {
+	return m.replacers[m.lastMatchedIx].Captures(line)
+}
+
+// Explain returns why the line does not match each of the patterns, or the
+// line filters it does not match.
+func (m *MultiReplacer) Explain(line []byte) []Explanation {
+	var explanations []Explanation
+	for _, f := range m.lineFilters {
+		if !f.Match(line) {
+			explanations = append(explanations, Explanation{
+				Pattern:  fmt.Sprint(f),
+				Mismatch: pattern.Mismatch{Reason: "the line is filtered out"},
+			})
+		}
+	}
+	if len(explanations) > 0 {
+		return explanations
+	}
+	for _, r := range m.replacers {
+		explanations = append(explanations, explain(r, line)...)
+	}
+	return explanations
+}
@@ -65,6 +65,11 @@ func (p parseError) Error() string {
 	return fmt.Sprintf("parse error at line %d, col %d: %s", p.line, p.col, p.msg)
 }
 
+// Position returns the line and the column where parsing failed, both 1-indexed.
+func (p parseError) Position() (line, col int) {
+	return p.line, p.col
+}
+
 func newParseError(msg string, line, col int) parseError {
 	return parseError{
 		msg:  msg,
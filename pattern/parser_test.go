@@ -72,3 +72,13 @@ func BenchmarkParseExpr(b *testing.B) {
 	}
 	require.NoError(b, err)
 }
+
+func Test_ParseErrorPosition(t *testing.T) {
+	_, err := parseExpr("")
+	require.Error(t, err)
+	pe, ok := err.(interface{ Position() (int, int) })
+	require.True(t, ok)
+	line, col := pe.Position()
+	require.Equal(t, 1, line)
+	require.Equal(t, 1, col)
+}
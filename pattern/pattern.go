@@ -3,6 +3,7 @@ package pattern
 import (
 	"bytes"
 	"errors"
+	"fmt"
 )
 
 var (
@@ -59,7 +60,7 @@ func ParseLineFilter(in []byte) (*Matcher, error) {
 			}
 		}
 	}
-	return &Matcher{e: e, longestLiteral: longestLiteral}, nil
+	return &Matcher{e: e, names: e.captures(), longestLiteral: longestLiteral}, nil
 }
 
 func ParseLiterals(in string) ([][]byte, error) {
@@ -150,10 +151,79 @@ func (m *Matcher) Matches(in []byte) [][]byte {
 	return result
 }
 
+// LongestLiteral returns the longest literal of the pattern, which is
+// contained in all the lines matched by Test, or nil if there is none.
+func (m *Matcher) LongestLiteral() []byte {
+	return m.longestLiteral
+}
+
+// Suffix returns the literal ending the pattern, which ends all the lines
+// matched by Test, or nil if the pattern ends with a capture.
+func (m *Matcher) Suffix() []byte {
+	if len(m.e) == 0 {
+		return nil
+	}
+	if l, ok := m.e[len(m.e)-1].(literals); ok {
+		return l
+	}
+	return nil
+}
+
 func (m *Matcher) Names() []string {
 	return m.names
 }
 
+// Mismatch explains why a line does not match a pattern.
+type Mismatch struct {
+	// Offset is the position in the line where matching failed.
+	Offset int
+	Reason string
+}
+
+// Explain walks the pattern node by node like Test, and returns why in does
+// not match, or nil if it matches.
+func (m *Matcher) Explain(in []byte) *Mismatch {
+	if len(m.e) == 0 {
+		if len(in) == 0 {
+			return nil
+		}
+		return &Mismatch{Reason: "an empty pattern only matches empty lines"}
+	}
+	var off int
+	for i := range m.e {
+		lit, ok := m.e[i].(literals)
+		if !ok {
+			continue
+		}
+		j := bytes.Index(in[off:], lit)
+		if j == -1 {
+			where := "in the line"
+			if i > 0 {
+				where = "after " + m.e[i-1].String()
+			}
+			return &Mismatch{Offset: off, Reason: fmt.Sprintf("literal %q not found %s", lit.String(), where)}
+		}
+		if i != 0 && j == 0 {
+			if c, ok := m.e[i-1].(capture); ok {
+				return &Mismatch{Offset: off, Reason: fmt.Sprintf("capture %s is empty", c)}
+			}
+			return &Mismatch{Offset: off, Reason: fmt.Sprintf("literal %q is repeated", lit.String())}
+		}
+		off += j + len(lit)
+	}
+	if len(in) == 0 {
+		return &Mismatch{Reason: "empty line"}
+	}
+	if c, ok := m.e[len(m.e)-1].(capture); ok {
+		if off == len(in) {
+			return &Mismatch{Offset: off, Reason: fmt.Sprintf("capture %s is empty", c)}
+		}
+	} else if off != len(in) {
+		return &Mismatch{Offset: off, Reason: fmt.Sprintf("trailing input %q after the end of the pattern", in[off:])}
+	}
+	return nil
+}
+
 func (m *Matcher) Test(in []byte) bool {
 	if len(m.longestLiteral) > 0 {
 		if !bytes.Contains(in, m.longestLiteral) {
@@ -333,3 +333,50 @@ func Test_ParseNodes(t *testing.T) {
 		})
 	}
 }
+
+func Test_matcher_Explain(t *testing.T) {
+	for _, tt := range fixtures {
+		t.Run(tt.expr, func(t *testing.T) {
+			m, err := New(tt.expr)
+			require.NoError(t, err)
+			mismatch := m.Explain([]byte(tt.in))
+			assert.Equal(t, tt.matches, mismatch == nil, "mismatch: %+v", mismatch)
+		})
+	}
+
+	for _, tt := range []struct {
+		expr     string
+		in       string
+		expected Mismatch
+	}{
+		{"[<date>] [error] <message>", "[Sun Dec 04] [notice] ok", Mismatch{Offset: 1, Reason: `literal "] [error] " not found after <date>`}},
+		{"foo <foo> bar", "xyz buzz bar", Mismatch{Offset: 0, Reason: `literal "foo " not found in the line`}},
+		{"<a> <b>", " b", Mismatch{Offset: 0, Reason: "capture <a> is empty"}},
+		{"<a> <b>", "a ", Mismatch{Offset: 2, Reason: "capture <b> is empty"}},
+		{"<a> end", "a end more", Mismatch{Offset: 5, Reason: `trailing input " more" after the end of the pattern`}},
+	} {
+		t.Run(tt.expr+"/"+tt.in, func(t *testing.T) {
+			m, err := New(tt.expr)
+			require.NoError(t, err)
+			require.False(t, m.Test([]byte(tt.in)))
+			mismatch := m.Explain([]byte(tt.in))
+			require.NotNil(t, mismatch)
+			assert.Equal(t, tt.expected, *mismatch)
+		})
+	}
+}
+
+func Test_matcher_Suffix(t *testing.T) {
+	for _, tt := range []struct {
+		expr     string
+		expected []byte
+	}{
+		{"foo <foo> bar", []byte(" bar")},
+		{"foo <foo>", nil},
+		{"foo", []byte("foo")},
+	} {
+		m, err := New(tt.expr)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, m.Suffix(), tt.expr)
+	}
+}
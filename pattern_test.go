@@ -1,8 +1,13 @@
 package patt_test
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/google/go-cmp/cmp"
+	"os"
 	"patt"
+	"reflect"
+	"slices"
 	"testing"
 )
 
@@ -319,3 +324,180 @@ func TestMakeMultiReplacer(t *testing.T) {
 		})
 	}
 }
+
+func TestMultiReplacer_Stats(t *testing.T) {
+	replacer, err := patt.NewMultiFilter([]string{"rare <x>", "common <x>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := replacer.Clone()
+	for _, line := range []string{"common a", "common b", "rare c", "none"} {
+		replacer.Match([]byte(line))
+	}
+	clone.Match([]byte("common d"))
+
+	got := replacer.Stats()
+	want := patt.MatchStats{Lines: 5, Tries: 2 + 2 + 1 + 2 + 2, Hits: []int64{1, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMultiReplacer_AdaptiveOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		patterns  []string
+		opts      []patt.MultiReplacerOption
+		firstLine string
+		wantOrder []int
+	}{
+		{
+			name:      "fixed order",
+			patterns:  []string{"x <x>", "common <x>"},
+			firstLine: "x 1",
+			wantOrder: []int{0, 1},
+		},
+		{
+			name:      "adaptive order",
+			patterns:  []string{"x <x>", "common <x>"},
+			opts:      []patt.MultiReplacerOption{patt.WithAdaptiveOrder()},
+			firstLine: "x 1",
+			wantOrder: []int{1, 0},
+		},
+		{
+			name:      "disjoint patterns",
+			patterns:  []string{"<x> rare", "<x> common"},
+			firstLine: "1 rare",
+			wantOrder: []int{1, 0},
+		},
+		{
+			name:      "overlapping suffixes",
+			patterns:  []string{"<x> rare common", "<x> common"},
+			firstLine: "1 rare common",
+			wantOrder: []int{0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replacer, err := patt.NewMultiFilter(tt.patterns, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range 5000 {
+				replacer.Match(fmt.Appendf(nil, "common %d", i))
+				replacer.Match(fmt.Appendf(nil, "%d common", i))
+			}
+			if got := replacer.Order(); !reflect.DeepEqual(got, tt.wantOrder) {
+				t.Errorf("Order() = %v, want %v", got, tt.wantOrder)
+			}
+			if !replacer.Match([]byte(tt.firstLine)) || replacer.MatchedPattern() != 0 {
+				t.Errorf("line %q should match the first pattern", tt.firstLine)
+			}
+		})
+	}
+}
+
+func TestMultiReplacer_Prefilter(t *testing.T) {
+	patterns := []string{"<a> error <b>", "<a> warn <b>", "<a> info <b>", "<a> <b>"}
+	template := "<b>"
+	lines := []string{"1 error x", "2 warn y", "3 debug z", "4 info w", "errors"}
+	prefiltered := makeMultiReplacer(t, patterns, template)
+	scanned, err := patt.NewMultiReplacer(patterns, template, patt.WithoutPrefilter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range lines {
+		matched := prefiltered.Match([]byte(line))
+		if want := scanned.Match([]byte(line)); matched != want {
+			t.Fatalf("Match(%q) = %v, want %v", line, matched, want)
+		}
+		if !matched {
+			continue
+		}
+		if got, want := string(prefiltered.Replace([]byte(line))), string(scanned.Replace([]byte(line))); got != want {
+			t.Errorf("Replace(%q) = %q, want %q", line, got, want)
+		}
+	}
+	// Only the patterns whose literal is found are tried.
+	if got, want := prefiltered.Stats().Tries, int64(1+1+1+1+0); got != want {
+		t.Errorf("Stats().Tries = %d, want %d", got, want)
+	}
+	if got, want := scanned.Stats().Tries, int64(1+2+4+3+4); got != want {
+		t.Errorf("Stats().Tries without prefilter = %d, want %d", got, want)
+	}
+}
+
+func TestMultiReplacer_AllPatterns(t *testing.T) {
+	patterns := []string{"<_> slow <_>", "<_> status=5<_>", "<_> fast <_>", "<_> status=2<_>"}
+	tests := []struct {
+		name string
+		opts []patt.MultiReplacerOption
+		line string
+		want []int
+	}{
+		{name: "first match", line: "GET slow status=500", want: []int{0}},
+		{name: "all matches", opts: []patt.MultiReplacerOption{patt.WithAllPatterns()}, line: "GET slow status=500", want: []int{0, 1}},
+		{name: "all matches without prefilter", opts: []patt.MultiReplacerOption{patt.WithAllPatterns(), patt.WithoutPrefilter()}, line: "GET slow status=500", want: []int{0, 1}},
+		{name: "single match", opts: []patt.MultiReplacerOption{patt.WithAllPatterns()}, line: "GET fast status=404", want: []int{2}},
+		{name: "no match", opts: []patt.MultiReplacerOption{patt.WithAllPatterns()}, line: "GET status=404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replacer, err := patt.NewMultiFilter(patterns, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched := replacer.Match([]byte(tt.line)); matched != (len(tt.want) > 0) {
+				t.Fatalf("Match(%q) = %v", tt.line, matched)
+			}
+			if got := replacer.MatchedPatterns(); !slices.Equal(got, tt.want) {
+				t.Errorf("MatchedPatterns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkMultiReplacer_Patterns matches the Apache log with n patterns,
+// only the last one matching the lines. A single pattern never uses the
+// prefilter, so it is only scanned.
+func BenchmarkMultiReplacer_Patterns(b *testing.B) {
+	data, err := os.ReadFile("testdata/Apache_2k.log")
+	if err != nil {
+		b.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	for _, n := range []int{1, 10, 100} {
+		patterns := make([]string, 0, n)
+		for i := range n - 1 {
+			patterns = append(patterns, fmt.Sprintf("[<date>] [rule%d] <message>", i))
+		}
+		patterns = append(patterns, "[<date>] [<level>] <message>")
+		for _, bench := range []struct {
+			name string
+			opts []patt.MultiReplacerOption
+		}{
+			{"prefilter", nil},
+			{"scan", []patt.MultiReplacerOption{patt.WithoutPrefilter()}},
+		} {
+			if n == 1 && bench.name == "prefilter" {
+				continue
+			}
+			b.Run(fmt.Sprintf("%d/%s", n, bench.name), func(b *testing.B) {
+				replacer, err := patt.NewMultiReplacer(patterns, "<message>", bench.opts...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(data)))
+				for b.Loop() {
+					for _, line := range lines {
+						if !replacer.Match(line) {
+							b.Fatalf("line %q not matched", line)
+						}
+					}
+				}
+			})
+		}
+	}
+}
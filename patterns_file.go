@@ -0,0 +1,128 @@
+package patt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"patt/pattern"
+)
+
+// PatternsFile holds the search patterns and the optional replace template
+// read from a patterns file.
+type PatternsFile struct {
+	Patterns []string
+	Template string
+}
+
+// PatternsFileError is the error of an invalid line of a patterns file. Col is
+// the column where the pattern could not be parsed, or 0 when unknown.
+type PatternsFileError struct {
+	Name string
+	Line int
+	Col  int
+	Err  error
+}
+
+func (e *PatternsFileError) Error() string {
+	if e.Col > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.Name, e.Line, e.Col, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Name, e.Line, e.Err)
+}
+
+func (e *PatternsFileError) Unwrap() error {
+	return e.Err
+}
+
+// newPatternsFileError returns the error of the pattern starting at column
+// offset+1 of the line, with the column where it could not be parsed if known.
+func newPatternsFileError(name string, line, offset int, err error) *PatternsFileError {
+	fileErr := &PatternsFileError{Name: name, Line: line, Err: err}
+	var posErr interface{ Position() (int, int) }
+	if errors.As(err, &posErr) {
+		_, col := posErr.Position()
+		fileErr.Col = col + offset
+	}
+	return fileErr
+}
+
+// templatePrefix starts the line of a patterns file holding the replace template.
+const templatePrefix = "=>"
+
+// ReadPatternsFile reads a patterns file, with one search pattern per line.
+// Blank lines and lines starting with # are ignored, and a line starting with
+// => holds the replace template. A leading backslash escapes a pattern that
+// starts with # or =>. Spaces are part of the patterns, except around the
+// template.
+func ReadPatternsFile(name string) (PatternsFile, error) {
+	var pf PatternsFile
+	f, err := os.Open(name)
+	if err != nil {
+		return pf, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, DefaultMaxLineLength)
+	templateLine := 0
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case strings.TrimSpace(line) == "", strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, templatePrefix):
+			if templateLine > 0 {
+				return pf, &PatternsFileError{Name: name, Line: lineNo, Err: fmt.Errorf("duplicate template, already defined at line %d", templateLine)}
+			}
+			pf.Template = strings.TrimSpace(strings.TrimPrefix(line, templatePrefix))
+			templateLine = lineNo
+			continue
+		}
+		offset := 0
+		if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\`+templatePrefix) {
+			line = line[1:]
+			offset = 1
+		}
+		if _, err := pattern.New(line); err != nil {
+			return pf, newPatternsFileError(name, lineNo, offset, err)
+		}
+		pf.Patterns = append(pf.Patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return pf, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(pf.Patterns) == 0 {
+		return pf, fmt.Errorf("%s: no search pattern", name)
+	}
+	return pf, nil
+}
+
+// loadPatternsFiles adds the patterns of the files to params. A template in
+// the files is used unless one is given on the command line, and the files
+// cannot have different templates.
+func loadPatternsFiles(params *CLIParams) error {
+	var patterns []string
+	template, templateFile := "", ""
+	for _, name := range params.PatternsFiles {
+		pf, err := ReadPatternsFile(name)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, pf.Patterns...)
+		if pf.Template == "" {
+			continue
+		}
+		if templateFile != "" && pf.Template != template {
+			return fmt.Errorf("%s: template conflicts with the one of %s", name, templateFile)
+		}
+		template, templateFile = pf.Template, name
+	}
+	params.SearchPatterns = append(patterns, params.SearchPatterns...)
+	if params.ReplaceTemplate == "" {
+		params.ReplaceTemplate = template
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package patt_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"patt"
+)
+
+func TestReadPatternsFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "patterns")
+	writeFile(t, name, "# comment\n"+
+		"<ip> - <user>\n"+
+		"\n"+
+		"   \n"+
+		"  indented <x>\r\n"+
+		`\# not a comment <y>`+"\n"+
+		`\=> not a template <z>`+"\n"+
+		"=>  <ip> <user> \n")
+
+	got, err := patt.ReadPatternsFile(name)
+	if err != nil {
+		t.Fatalf("ReadPatternsFile() error = %v", err)
+	}
+	want := patt.PatternsFile{
+		Patterns: []string{"<ip> - <user>", "  indented <x>", "# not a comment <y>", "=> not a template <z>"},
+		Template: "<ip> <user>",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadPatternsFile() = %#v, want %#v", got, want)
+	}
+}
+
+func TestReadPatternsFile_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		expectErr string
+	}{
+		{
+			name:      "invalid pattern",
+			content:   "# comment\n<a> <b>\n<a><b>\n",
+			expectErr: ":3: found consecutive capture '<a><b>': invalid expression",
+		},
+		{
+			name:      "duplicate capture",
+			content:   "<a> <b>\n<a> - <a>\n",
+			expectErr: ":2: duplicate capture name (a): invalid expression",
+		},
+		{
+			name:      "parse error column",
+			content:   "<a> <b>\n\xff\n",
+			expectErr: ":2:1: parse error at line 1, col 1: syntax error: unexpected $end, expecting IDENTIFIER or LITERAL",
+		},
+		{
+			name:      "duplicate template",
+			content:   "<a>\n=> <a>\n=> <a>!\n",
+			expectErr: ":3: duplicate template, already defined at line 2",
+		},
+		{
+			name:      "no pattern",
+			content:   "# comment\n=> <a>\n",
+			expectErr: ": no search pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "patterns")
+			writeFile(t, name, tt.content)
+
+			_, err := patt.ReadPatternsFile(name)
+			if err == nil {
+				t.Fatal("ReadPatternsFile() should fail")
+			}
+			if want := name + tt.expectErr; err.Error() != want {
+				t.Errorf("ReadPatternsFile() error = %q, want %q", err, want)
+			}
+		})
+	}
+
+	_, err := patt.ReadPatternsFile(filepath.Join(t.TempDir(), "missing"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadPatternsFile() error = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,122 @@
+package patt
+
+// literalSet is an Aho-Corasick automaton, finding which of a set of literals
+// a line contains in a single pass over the line.
+//
+// The automaton is a DFA whose transitions are indexed by byte classes: the
+// bytes not found in any literal share a single class.
+type literalSet struct {
+	classes    [256]uint8
+	numClasses int
+	// next[state*numClasses+class] is the offset state*numClasses of the
+	// state after reading a byte of class, negated when literals end there.
+	next []int32
+	// outputs[state] are the literals ending at state, including through
+	// failure links.
+	outputs [][]int32
+}
+
+// newLiteralSet builds the automaton of the literals, which must not be empty.
+func newLiteralSet(literals [][]byte) *literalSet {
+	s := &literalSet{}
+	var used [256]bool
+	for _, l := range literals {
+		for _, b := range l {
+			used[b] = true
+		}
+	}
+	s.numClasses = 1 // Class 0 is for the bytes not found in literals.
+	for b := range used {
+		if used[b] {
+			s.classes[b] = uint8(s.numClasses)
+			s.numClasses++
+		}
+	}
+	if s.numClasses > 256 {
+		// All the bytes are used, no need for the shared class.
+		s.numClasses = 256
+		for b := range s.classes {
+			s.classes[b] = uint8(b)
+		}
+	}
+
+	// Build the trie, -1 being a missing transition.
+	s.next = s.newState(nil)
+	s.outputs = [][]int32{nil}
+	for i, l := range literals {
+		state := int32(0)
+		for _, b := range l {
+			ix := int(state)*s.numClasses + int(s.classes[b])
+			if s.next[ix] < 0 {
+				s.next[ix] = int32(len(s.outputs))
+				s.next = s.newState(s.next)
+				s.outputs = append(s.outputs, nil)
+			}
+			state = s.next[ix]
+		}
+		s.outputs[state] = append(s.outputs[state], int32(i))
+	}
+
+	// Compute the failure links breadth first, turning missing transitions
+	// into the transitions of the failure state.
+	fail := make([]int32, len(s.outputs))
+	var queue []int32
+	for c := range s.numClasses {
+		if next := s.next[c]; next < 0 {
+			s.next[c] = 0
+		} else {
+			queue = append(queue, next)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for c := range s.numClasses {
+			ix := int(state)*s.numClasses + c
+			failNext := s.next[int(fail[state])*s.numClasses+c]
+			if next := s.next[ix]; next < 0 {
+				s.next[ix] = failNext
+			} else {
+				fail[next] = failNext
+				s.outputs[next] = append(s.outputs[next], s.outputs[failNext]...)
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	// Turn states into offsets for find.
+	for i, state := range s.next {
+		s.next[i] = state * int32(s.numClasses)
+		if len(s.outputs[state]) > 0 {
+			s.next[i] = -s.next[i]
+		}
+	}
+	return s
+}
+
+func (s *literalSet) newState(next []int32) []int32 {
+	for range s.numClasses {
+		next = append(next, -1)
+	}
+	return next
+}
+
+// find sets found[i] for each literal i contained in line, and appends i to
+// hits the first time it is found.
+func (s *literalSet) find(line []byte, found []bool, hits []int32) []int32 {
+	next, classes := s.next, &s.classes
+	offset := int32(0)
+	for _, b := range line {
+		offset = next[offset+int32(classes[b])]
+		if offset < 0 {
+			offset = -offset
+			for _, i := range s.outputs[int(offset)/s.numClasses] {
+				if !found[i] {
+					found[i] = true
+					hits = append(hits, i)
+				}
+			}
+		}
+	}
+	return hits
+}
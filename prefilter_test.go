@@ -0,0 +1,61 @@
+package patt
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestLiteralSet(t *testing.T) {
+	literals := [][]byte{[]byte("he"), []byte("she"), []byte("his"), []byte("hers"), []byte("é"), []byte("error ")}
+	set := newLiteralSet(literals)
+
+	for _, tt := range []struct {
+		line string
+		want []bool
+	}{
+		{"ushers", []bool{true, true, false, true, false, false}},
+		{"this error is", []bool{false, false, true, false, false, true}},
+		{"café", []bool{false, false, false, false, true, false}},
+		{"", []bool{false, false, false, false, false, false}},
+	} {
+		found := make([]bool, len(literals))
+		hits := set.find([]byte(tt.line), found, nil)
+		if !reflect.DeepEqual(found, tt.want) {
+			t.Errorf("find(%q) = %v, want %v", tt.line, found, tt.want)
+		}
+		if count := bytes.Count([]byte(fmt.Sprint(tt.want)), []byte("true")); len(hits) != count {
+			t.Errorf("find(%q) hits = %v, want %d literals", tt.line, hits, count)
+		}
+	}
+}
+
+func TestLiteralSet_Random(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	randomBytes := func(n int, alphabet string) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[rnd.IntN(len(alphabet))]
+		}
+		return b
+	}
+	for range 100 {
+		literals := make([][]byte, 1+rnd.IntN(20))
+		for i := range literals {
+			literals[i] = randomBytes(1+rnd.IntN(4), "abc")
+		}
+		set := newLiteralSet(literals)
+		for range 20 {
+			line := randomBytes(rnd.IntN(30), "abcd")
+			found := make([]bool, len(literals))
+			set.find(line, found, nil)
+			for i, l := range literals {
+				if found[i] != bytes.Contains(line, l) {
+					t.Fatalf("find(%q) for %q = %v, want %v", line, l, found[i], !found[i])
+				}
+			}
+		}
+	}
+}
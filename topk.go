@@ -0,0 +1,119 @@
+package patt
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// topKCapacityFactor is how many counters are kept per reported value. More
+// counters make the counts of the reported values more accurate.
+const topKCapacityFactor = 10
+
+// TopK reports the most frequent values of a capture using the Space-Saving
+// algorithm, so memory stays bounded regardless of the number of distinct values.
+type TopK struct {
+	capture  string
+	k        int
+	capacity int
+	counters map[string]*topKCounter
+	heap     topKHeap
+}
+
+type topKCounter struct {
+	value string
+	count int
+	// overestimate is the maximum error of count, inherited from the evicted counter.
+	overestimate int
+	ix           int
+}
+
+func NewTopK(capture string, k int) (*TopK, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("invalid number of top values %d, must be positive", k)
+	}
+	capacity := k * topKCapacityFactor
+	return &TopK{
+		capture:  capture,
+		k:        k,
+		capacity: capacity,
+		counters: make(map[string]*topKCounter, capacity),
+	}, nil
+}
+
+func (t *TopK) Add(captures Captures) {
+	value, ok := captures.Get(t.capture)
+	if !ok {
+		return
+	}
+	if c, ok := t.counters[string(value)]; ok {
+		c.count++
+		heap.Fix(&t.heap, c.ix)
+		return
+	}
+	if len(t.heap) < t.capacity {
+		c := &topKCounter{value: string(value), count: 1}
+		t.counters[c.value] = c
+		heap.Push(&t.heap, c)
+		return
+	}
+	// Replace the least frequent value, which may have been undercounted by
+	// at most its count.
+	c := t.heap[0]
+	delete(t.counters, c.value)
+	c.value = string(value)
+	c.overestimate = c.count
+	c.count++
+	t.counters[c.value] = c
+	heap.Fix(&t.heap, 0)
+}
+
+// Report writes the k most frequent values, most frequent first, as
+// "count<TAB>value" lines. Counts that may be overestimated, because the
+// value replaced a less frequent one, are followed by the lower bound of the
+// actual count, as "<TAB>(at least n)".
+func (t *TopK) Report(w io.Writer) error {
+	top := slices.Clone(t.heap)
+	slices.SortFunc(top, func(a, b *topKCounter) int {
+		if c := cmp.Compare(b.count, a.count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.value, b.value)
+	})
+	bw := bufio.NewWriter(w)
+	for _, c := range top[:min(t.k, len(top))] {
+		if c.overestimate > 0 {
+			fmt.Fprintf(bw, "%d\t%s\t(at least %d)\n", c.count, c.value, c.count-c.overestimate)
+		} else {
+			fmt.Fprintf(bw, "%d\t%s\n", c.count, c.value)
+		}
+	}
+	return bw.Flush()
+}
+
+// topKHeap is a min-heap of counters ordered by count.
+type topKHeap []*topKCounter
+
+func (h topKHeap) Len() int           { return len(h) }
+func (h topKHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h topKHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].ix = i
+	h[j].ix = j
+}
+
+func (h *topKHeap) Push(x any) {
+	c := x.(*topKCounter)
+	c.ix = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *topKHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
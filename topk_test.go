@@ -0,0 +1,78 @@
+package patt_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"patt"
+)
+
+func addValues(a patt.Aggregator, name string, values ...string) {
+	for _, v := range values {
+		a.Add(patt.Captures{Names: []string{name}, Values: [][]byte{[]byte(v)}})
+	}
+}
+
+func TestTopK(t *testing.T) {
+	top, err := patt.NewTopK("ip", 2)
+	if err != nil {
+		t.Fatalf("NewTopK() error = %v", err)
+	}
+	addValues(top, "ip", "10.0.0.1", "10.0.0.2", "10.0.0.1", "10.0.0.3", "10.0.0.2", "10.0.0.1")
+	top.Add(patt.Captures{Names: []string{"other"}, Values: [][]byte{[]byte("10.0.0.3")}})
+
+	var out bytes.Buffer
+	if err := top.Report(&out); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	expected := "3\t10.0.0.1\n2\t10.0.0.2\n"
+	if out.String() != expected {
+		t.Errorf("Report() = %q, want %q", out.String(), expected)
+	}
+}
+
+func TestTopK_BoundedMemory(t *testing.T) {
+	top, err := patt.NewTopK("ip", 1)
+	if err != nil {
+		t.Fatalf("NewTopK() error = %v", err)
+	}
+	// A heavy hitter interleaved with many more distinct values than counters.
+	for i := range 10000 {
+		addValues(top, "ip", "heavy", fmt.Sprintf("noise-%d", i))
+	}
+
+	var out bytes.Buffer
+	if err := top.Report(&out); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if !bytes.HasSuffix(out.Bytes(), []byte("\theavy\n")) {
+		t.Errorf("Report() = %q, want heavy hitter", out.String())
+	}
+}
+
+func TestTopK_ErrorBound(t *testing.T) {
+	top, err := patt.NewTopK("ip", 1)
+	if err != nil {
+		t.Fatalf("NewTopK() error = %v", err)
+	}
+	// Fill the 10 counters, "late" then replaces a value seen once.
+	for i := range 10 {
+		addValues(top, "ip", fmt.Sprintf("early-%d", i))
+	}
+	addValues(top, "ip", "late", "late")
+
+	var out bytes.Buffer
+	if err := top.Report(&out); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if expected := "3\tlate\t(at least 2)\n"; out.String() != expected {
+		t.Errorf("Report() = %q, want %q", out.String(), expected)
+	}
+}
+
+func TestNewTopK_InvalidK(t *testing.T) {
+	if _, err := patt.NewTopK("ip", 0); err == nil {
+		t.Error("NewTopK() with k = 0 should fail")
+	}
+}
@@ -0,0 +1,119 @@
+package patt
+
+import (
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WalkOptions controls how directories given as input are expanded.
+type WalkOptions struct {
+	// Include, when not empty, restricts the files found in directories to
+	// those whose base name matches one of the globs.
+	Include []string
+	// Exclude skips the files and directories whose base name matches one of the globs.
+	Exclude []string
+	// Hidden includes files and directories whose name starts with a dot.
+	Hidden bool
+	// FollowSymlinks follows symbolic links found in directories, which are
+	// skipped otherwise.
+	FollowSymlinks bool
+}
+
+// WalkInputs returns the paths to process for the given inputs. Files are
+// returned as they are, directories are walked recursively in lexical order.
+// The walk is lazy, so processing can start with the first file found.
+//
+// Paths that cannot be read are returned as well, so that opening them
+// reports the error.
+func WalkInputs(paths []string, opts WalkOptions) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil || !info.IsDir() {
+				if !yield(path) {
+					return
+				}
+				continue
+			}
+			w := walker{opts: opts, visited: make(map[string]struct{})}
+			if !w.walkDir(path, yield) {
+				return
+			}
+		}
+	}
+}
+
+type walker struct {
+	opts WalkOptions
+	// visited holds the resolved directories, to avoid cycles when following links.
+	visited map[string]struct{}
+}
+
+// walkDir yields the files in dir, it returns false when yield asks to stop.
+func (w *walker) walkDir(dir string, yield func(string) bool) bool {
+	if w.opts.FollowSymlinks {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			if _, ok := w.visited[real]; ok {
+				return true
+			}
+			w.visited[real] = struct{}{}
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return yield(dir)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !w.opts.Hidden && strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if matchAny(w.opts.Exclude, entry.Name()) {
+			continue
+		}
+		typ := entry.Type()
+		if typ&fs.ModeSymlink != 0 {
+			if !w.opts.FollowSymlinks {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				// Dangling link.
+				continue
+			}
+			typ = info.Mode().Type()
+		}
+		switch {
+		case typ.IsDir():
+			if !w.walkDir(path, yield) {
+				return false
+			}
+		case typ.IsRegular():
+			if len(w.opts.Include) > 0 && !matchAny(w.opts.Include, entry.Name()) {
+				continue
+			}
+			if !yield(path) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchAny(globs []string, name string) bool {
+	for _, glob := range globs {
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,113 @@
+package patt_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"patt"
+)
+
+// makeTree creates files (and their directories) relative to a temporary
+// directory and returns it.
+func makeTree(t *testing.T, files ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, f := range files {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	return root
+}
+
+func TestWalkInputs(t *testing.T) {
+	root := makeTree(t,
+		"a.log",
+		"b.log.gz",
+		".hidden.log",
+		"sub/c.log",
+		"sub/d.txt",
+		".git/e.log",
+		"vendor/f.log",
+	)
+	if err := os.Symlink(filepath.Join(root, "sub"), filepath.Join(root, "link")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	// A cycle that must not be walked forever.
+	if err := os.Symlink(root, filepath.Join(root, "sub", "loop")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		paths    []string
+		opts     patt.WalkOptions
+		expected []string
+	}{
+		{
+			name:     "defaults skip hidden files and symlinks",
+			paths:    []string{root},
+			expected: []string{"a.log", "b.log.gz", "sub/c.log", "sub/d.txt", "vendor/f.log"},
+		},
+		{
+			name:     "include and exclude",
+			paths:    []string{root},
+			opts:     patt.WalkOptions{Include: []string{"*.log"}, Exclude: []string{"vendor"}},
+			expected: []string{"a.log", "sub/c.log"},
+		},
+		{
+			name:     "hidden",
+			paths:    []string{root},
+			opts:     patt.WalkOptions{Hidden: true, Include: []string{"*.log"}},
+			expected: []string{".git/e.log", ".hidden.log", "a.log", "sub/c.log", "vendor/f.log"},
+		},
+		{
+			name:     "follow symlinks",
+			paths:    []string{root},
+			opts:     patt.WalkOptions{FollowSymlinks: true, Include: []string{"*.log"}},
+			expected: []string{"a.log", "link/c.log", "vendor/f.log"},
+		},
+		{
+			name:     "files are not filtered",
+			paths:    []string{filepath.Join(root, "sub", "d.txt"), filepath.Join(root, "missing.log")},
+			opts:     patt.WalkOptions{Include: []string{"*.log"}},
+			expected: []string{"sub/d.txt", "missing.log"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for path := range patt.WalkInputs(tt.paths, tt.opts) {
+				rel, err := filepath.Rel(root, path)
+				if err != nil {
+					t.Fatalf("unexpected path %s", path)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("WalkInputs() (-expected +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWalkInputs_Stop(t *testing.T) {
+	root := makeTree(t, "a.log", "b.log", "c.log")
+	var got []string
+	for path := range patt.WalkInputs([]string{root, root}, patt.WalkOptions{}) {
+		got = append(got, filepath.Base(path))
+		if len(got) == 2 {
+			break
+		}
+	}
+	if diff := cmp.Diff([]string{"a.log", "b.log"}, got); diff != "" {
+		t.Errorf("WalkInputs() (-expected +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,390 @@
+package patt
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"patt/pattern"
+)
+
+// Where is a condition on the captures of a line, like the label filters of
+// LogQL: comparisons of a capture with a number, a duration, a byte size, a
+// string or a regular expression, combined with and, or and parentheses.
+//
+//	status >= 500 and (latency > 250ms or size > 1MB) and path !~ "/health.*"
+//
+// The type of a comparison is the type of its value. Captures that cannot be
+// parsed as this type do not satisfy the comparison.
+type Where struct {
+	expr whereExpr
+	text string
+}
+
+// ParseWhere parses a condition.
+func ParseWhere(s string) (*Where, error) {
+	p := &whereParser{in: s}
+	p.next()
+	expr, err := p.parseOr()
+	if err == nil {
+		err = p.err
+	}
+	if err == nil && p.tok.kind != whereEOF {
+		err = p.errorf("unexpected %s", p.tok)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition '%s': %w", s, err)
+	}
+	return &Where{expr: expr, text: s}, nil
+}
+
+// Match reports whether the captures satisfy the condition.
+func (w *Where) Match(c Captures) bool {
+	return w.expr.eval(c)
+}
+
+// Names returns the names of the captures compared by the condition.
+func (w *Where) Names() []string {
+	var names []string
+	w.expr.names(&names)
+	return names
+}
+
+// explain returns why the captures do not satisfy the condition.
+func (w *Where) explain(c Captures) Explanation {
+	reason := "the condition is false"
+	seen := map[string]bool{}
+	for _, name := range w.Names() {
+		value, ok := c.Get(name)
+		if !ok || seen[name] {
+			continue
+		}
+		if len(seen) == 0 {
+			reason += " for"
+		}
+		seen[name] = true
+		reason += fmt.Sprintf(" %s=%q", name, value)
+	}
+	return Explanation{Pattern: "where " + w.text, Mismatch: pattern.Mismatch{Reason: reason}}
+}
+
+func (w *Where) String() string {
+	return w.text
+}
+
+type whereExpr interface {
+	eval(c Captures) bool
+	names(names *[]string)
+}
+
+type whereAnd struct{ left, right whereExpr }
+
+func (e whereAnd) eval(c Captures) bool { return e.left.eval(c) && e.right.eval(c) }
+
+func (e whereAnd) names(names *[]string) {
+	e.left.names(names)
+	e.right.names(names)
+}
+
+type whereOr struct{ left, right whereExpr }
+
+func (e whereOr) eval(c Captures) bool { return e.left.eval(c) || e.right.eval(c) }
+
+func (e whereOr) names(names *[]string) {
+	e.left.names(names)
+	e.right.names(names)
+}
+
+// whereCompare compares a capture with a value, parsing the capture with
+// parse for the numeric types.
+type whereCompare struct {
+	name  string
+	op    string
+	parse func(string) (float64, bool)
+	num   float64
+	str   string
+	re    *regexp.Regexp
+}
+
+func (e whereCompare) names(names *[]string) {
+	*names = append(*names, e.name)
+}
+
+func (e whereCompare) eval(c Captures) bool {
+	value, ok := c.Get(e.name)
+	if !ok {
+		return false
+	}
+	switch {
+	case e.re != nil:
+		return e.re.Match(value) == (e.op == "=~")
+	case e.parse == nil:
+		return (string(value) == e.str) == (e.op == "==")
+	}
+	n, ok := e.parse(string(value))
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "==":
+		return n == e.num
+	case "!=":
+		return n != e.num
+	case ">":
+		return n > e.num
+	case ">=":
+		return n >= e.num
+	case "<":
+		return n < e.num
+	}
+	return n <= e.num
+}
+
+func parseNumber(s string) (float64, bool) {
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return n, err == nil
+}
+
+func parseDurationSeconds(s string) (float64, bool) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	return d.Seconds(), err == nil
+}
+
+// byteUnits are the multipliers of the byte size units, in lower case.
+var byteUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "m": 1e6, "mb": 1e6, "g": 1e9, "gb": 1e9, "t": 1e12, "tb": 1e12, "p": 1e15, "pb": 1e15,
+	"ki": 1 << 10, "kib": 1 << 10, "mi": 1 << 20, "mib": 1 << 20, "gi": 1 << 30, "gib": 1 << 30,
+	"ti": 1 << 40, "tib": 1 << 40, "pi": 1 << 50, "pib": 1 << 50,
+}
+
+// parseBytes parses a byte size such as 512, 10KB or 1.5 MiB.
+func parseBytes(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) })
+	if i < 0 {
+		i = len(s)
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s[:i]), 64)
+	if err != nil {
+		return 0, false
+	}
+	unit, ok := byteUnits[strings.ToLower(s[i:])]
+	return n * unit, ok
+}
+
+type whereTokenKind int
+
+const (
+	whereEOF whereTokenKind = iota
+	whereIdent
+	whereOp
+	whereString
+	whereValue
+	whereLParen
+	whereRParen
+	whereComma
+)
+
+type whereToken struct {
+	kind whereTokenKind
+	text string
+	pos  int
+}
+
+func (t whereToken) String() string {
+	if t.kind == whereEOF {
+		return "end of condition"
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+type whereParser struct {
+	in  string
+	pos int
+	tok whereToken
+	err error
+}
+
+func (p *whereParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+// next reads the next token into p.tok, recording lexical errors in p.err.
+func (p *whereParser) next() {
+	for p.pos < len(p.in) && unicode.IsSpace(rune(p.in[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	p.tok = whereToken{pos: start}
+	if p.pos == len(p.in) {
+		return
+	}
+	c := p.in[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		p.tok.kind = whereLParen
+	case c == ')':
+		p.pos++
+		p.tok.kind = whereRParen
+	case c == ',':
+		p.pos++
+		p.tok.kind = whereComma
+	case strings.IndexByte("=!<>", c) >= 0:
+		p.pos++
+		if p.pos < len(p.in) && strings.IndexByte("=~", p.in[p.pos]) >= 0 {
+			p.pos++
+		}
+		p.tok.kind = whereOp
+	case c == '"' || c == '`':
+		i := p.pos + 1
+		for i < len(p.in) && p.in[i] != c {
+			if c == '"' && p.in[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(p.in) {
+			p.err = fmt.Errorf("at offset %d: unterminated string", start)
+			p.pos = len(p.in)
+			return
+		}
+		p.pos = i + 1
+		p.tok.kind = whereString
+	case unicode.IsLetter(rune(c)) || c == '_':
+		for p.pos < len(p.in) && isWordByte(p.in[p.pos]) {
+			p.pos++
+		}
+		p.tok.kind = whereIdent
+	default:
+		for p.pos < len(p.in) && (isWordByte(p.in[p.pos]) || strings.IndexByte(".+-", p.in[p.pos]) >= 0) {
+			p.pos++
+		}
+		if p.pos == start {
+			p.err = fmt.Errorf("at offset %d: unexpected character %q", start, c)
+			p.pos = len(p.in)
+			return
+		}
+		p.tok.kind = whereValue
+	}
+	p.tok.text = p.in[start:p.pos]
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *whereParser) isKeyword(keyword string) bool {
+	return p.tok.kind == whereIdent && strings.EqualFold(p.tok.text, keyword)
+}
+
+// parseOr parses and expressions separated by or.
+func (p *whereParser) parseOr() (whereExpr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.isKeyword("or") {
+		p.next()
+		var right whereExpr
+		right, err = p.parseAnd()
+		left = whereOr{left, right}
+	}
+	return left, err
+}
+
+// parseAnd parses comparisons and parenthesized expressions separated by and
+// or commas.
+func (p *whereParser) parseAnd() (whereExpr, error) {
+	left, err := p.parsePrimary()
+	for err == nil && (p.isKeyword("and") || p.tok.kind == whereComma) {
+		p.next()
+		var right whereExpr
+		right, err = p.parsePrimary()
+		left = whereAnd{left, right}
+	}
+	return left, err
+}
+
+func (p *whereParser) parsePrimary() (whereExpr, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind == whereLParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.err != nil {
+			return nil, p.err
+		}
+		if p.tok.kind != whereRParen {
+			return nil, p.errorf("expected ')', got %s", p.tok)
+		}
+		p.next()
+		return expr, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *whereParser) parseCompare() (whereExpr, error) {
+	if p.tok.kind != whereIdent {
+		return nil, p.errorf("expected a capture name, got %s", p.tok)
+	}
+	e := whereCompare{name: p.tok.text}
+	p.next()
+	if p.err != nil {
+		return nil, p.err
+	}
+	switch p.tok.text {
+	case "=", "==":
+		e.op = "=="
+	case "!=", ">", ">=", "<", "<=", "=~", "!~":
+		e.op = p.tok.text
+	}
+	if p.tok.kind != whereOp || e.op == "" {
+		return nil, p.errorf("expected a comparison operator, got %s", p.tok)
+	}
+	p.next()
+	if p.err != nil {
+		return nil, p.err
+	}
+	value := p.tok
+	p.next()
+
+	switch value.kind {
+	case whereString:
+		s, err := strconv.Unquote(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("at offset %d: invalid string %s", value.pos, value.text)
+		}
+		switch e.op {
+		case "=~", "!~":
+			// Like in LogQL, regular expressions match the whole value.
+			re, err := regexp.Compile("^(?:" + s + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("at offset %d: invalid regular expression: %w", value.pos, err)
+			}
+			e.re = re
+		case "==", "!=":
+			e.str = s
+		default:
+			return nil, fmt.Errorf("at offset %d: cannot compare strings with %s", value.pos, e.op)
+		}
+		return e, nil
+	case whereValue, whereIdent:
+		if e.op == "=~" || e.op == "!~" {
+			return nil, fmt.Errorf("at offset %d: the regular expression must be a string", value.pos)
+		}
+		for _, parse := range []func(string) (float64, bool){parseNumber, parseDurationSeconds, parseBytes} {
+			if n, ok := parse(value.text); ok {
+				e.parse, e.num = parse, n
+				return e, nil
+			}
+		}
+		return nil, fmt.Errorf("at offset %d: invalid number, duration or byte size %s", value.pos, value.text)
+	}
+	return nil, fmt.Errorf("at offset %d: expected a value, got %s", value.pos, value)
+}
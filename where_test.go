@@ -0,0 +1,96 @@
+package patt_test
+
+import (
+	"strings"
+	"testing"
+
+	"patt"
+)
+
+func TestWhere_Match(t *testing.T) {
+	captures := patt.Captures{
+		Names: []string{"status", "latency", "size", "path", "level"},
+		Values: [][]byte{
+			[]byte("503"), []byte("1.5s"), []byte("12KiB"), []byte("/api/users"), []byte("error"),
+		},
+	}
+	tests := []struct {
+		where string
+		want  bool
+	}{
+		{where: "status >= 500", want: true},
+		{where: "status > 503", want: false},
+		{where: "status == 503", want: true},
+		{where: "status = 503.0", want: true},
+		{where: "status != 503", want: false},
+		{where: "status < 1e3", want: true},
+		{where: "latency > 250ms", want: true},
+		{where: "latency <= 1s", want: false},
+		{where: "size > 10KB", want: true},
+		{where: "size >= 12KiB", want: true},
+		{where: "size < 1MB", want: true},
+		{where: `path = "/api/users"`, want: true},
+		{where: "path != `/api/users`", want: false},
+		{where: `path =~ "/api/.*"`, want: true},
+		{where: `path =~ "/api"`, want: false},
+		{where: `path !~ "/health.*"`, want: true},
+		{where: "level = 500", want: false},
+		{where: "missing = 1", want: false},
+		{where: `status >= 500 and level = "info"`, want: false},
+		{where: `status >= 500, level = "error"`, want: true},
+		{where: `level = "info" or status >= 500`, want: true},
+		{where: `status < 500 or level = "info" and latency > 1s`, want: false},
+		{where: `(status < 500 or level = "error") AND latency > 1s`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.where, func(t *testing.T) {
+			w, err := patt.ParseWhere(tt.where)
+			if err != nil {
+				t.Fatalf("ParseWhere() error = %v", err)
+			}
+			if got := w.Match(captures); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWhere_Errors(t *testing.T) {
+	tests := []struct {
+		where string
+		want  string
+	}{
+		{where: "", want: "expected a capture name"},
+		{where: "status", want: "expected a comparison operator"},
+		{where: "status >", want: "expected a value"},
+		{where: "status > 5xx", want: "invalid number, duration or byte size"},
+		{where: `path > "a"`, want: "cannot compare strings"},
+		{where: "path =~ abc", want: "must be a string"},
+		{where: `path =~ "("`, want: "invalid regular expression"},
+		{where: `path = "abc`, want: "unterminated string"},
+		{where: "(status > 5", want: "expected ')'"},
+		{where: "status > 5 status", want: "unexpected 'status'"},
+		{where: "status > 5 @", want: "unexpected character"},
+		{where: "status => 5", want: "expected a value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.where, func(t *testing.T) {
+			_, err := patt.ParseWhere(tt.where)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseWhere() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhere_Names(t *testing.T) {
+	w, err := patt.ParseWhere(`status >= 500 and (latency > 1s or path = "/")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(w.Names(), ","), "status,latency,path"; got != want {
+		t.Errorf("Names() = %s, want %s", got, want)
+	}
+}